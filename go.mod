@@ -13,4 +13,5 @@ require (
 	k8s.io/client-go v0.19.15
 	k8s.io/klog/v2 v2.10.0 // indirect
 	k8s.io/kubectl v0.19.15
+	sigs.k8s.io/yaml v1.2.0
 )
@@ -1,5 +1,65 @@
 package api
 
+import (
+	"golang.org/x/xerrors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+var _ = error(&Error{})
+
+// Error is a serializable representation of an error, suitable for
+// storing in CheckResult details. Go errors generally marshal to an
+// empty JSON object, which loses the reason a check failed.
+type Error struct {
+	// Message is the message of the error.
+	Message string `json:"message"`
+
+	// Chain contains the messages of the errors wrapped by this error,
+	// outermost first. The last entry is the root cause.
+	Chain []string `json:"chain,omitempty"`
+
+	// Reason and Code are set if the error, or any error it wraps, is
+	// a Kubernetes API status error.
+	Reason string `json:"reason,omitempty"`
+	Code   int32  `json:"code,omitempty"`
+
+	err error
+}
+
+// NewError creates an Error from the given error. It returns nil if
+// err is nil.
+func NewError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+
+	e := &Error{
+		Message: err.Error(),
+		err:     err,
+	}
+
+	for wrapped := xerrors.Unwrap(err); wrapped != nil; wrapped = xerrors.Unwrap(wrapped) {
+		e.Chain = append(e.Chain, wrapped.Error())
+	}
+
+	var status apierrors.APIStatus
+	if xerrors.As(err, &status) {
+		e.Reason = string(status.Status().Reason)
+		e.Code = status.Status().Code
+	}
+
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the original error.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
 // ErrorResult returns a CheckResult when an error occurs.
 func ErrorResult(name string, summary string, err error) *CheckResult {
 	return &CheckResult{
@@ -7,7 +67,7 @@ func ErrorResult(name string, summary string, err error) *CheckResult {
 		State:   StateFailed,
 		Summary: summary,
 		Details: map[string]interface{}{
-			"error": err,
+			"error": NewError(err),
 		},
 	}
 }
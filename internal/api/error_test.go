@@ -1,9 +1,14 @@
 package api_test
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 
+	"golang.org/x/xerrors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
 	"cdr.dev/coder-doctor/internal/api"
 	"cdr.dev/slog/sloggers/slogtest/assert"
 )
@@ -17,5 +22,42 @@ func TestErrorResult(t *testing.T) {
 	assert.Equal(t, "name matches", "check-name", res.Name)
 	assert.Equal(t, "state matches", api.StateFailed, res.State)
 	assert.Equal(t, "summary matches", "check failed", res.Summary)
-	assert.Equal(t, "error matches", err, res.Details["error"])
+
+	detail, ok := res.Details["error"].(*api.Error)
+	assert.True(t, "error is an *api.Error", ok)
+	assert.Equal(t, "error message matches", err.Error(), detail.Message)
+	assert.True(t, "error unwraps to original", xerrors.Is(detail, err))
+}
+
+func TestNewError(t *testing.T) {
+	t.Parallel()
+
+	t.Run("nil", func(t *testing.T) {
+		t.Parallel()
+
+		assert.True(t, "nil error", api.NewError(nil) == nil)
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		t.Parallel()
+
+		root := xerrors.New("connection refused")
+		err := xerrors.Errorf("get version: %w", root)
+
+		out, merr := json.Marshal(api.NewError(err))
+		assert.Success(t, "marshal error", merr)
+		assert.Equal(t, "json matches",
+			`{"message":"get version: connection refused","chain":["connection refused"]}`,
+			string(out))
+	})
+
+	t.Run("kubernetes-status", func(t *testing.T) {
+		t.Parallel()
+
+		statusErr := apierrors.NewForbidden(schema.GroupResource{Resource: "pods"}, "", xerrors.New("no"))
+		e := api.NewError(xerrors.Errorf("list pods: %w", statusErr))
+		assert.Equal(t, "reason matches", "Forbidden", e.Reason)
+		assert.Equal(t, "code matches", int32(403), e.Code)
+		assert.True(t, "unwraps to status error", apierrors.IsForbidden(e))
+	})
 }
@@ -0,0 +1,33 @@
+package api
+
+import "fmt"
+
+// Exit codes used by coder-doctor. These are part of the command-line
+// interface, and are documented in the README.
+const (
+	// ExitCodeOK indicates that no check produced a result at or above
+	// the configured failure threshold.
+	ExitCodeOK = 0
+
+	// ExitCodeError indicates that the tool itself failed, for example
+	// due to invalid flags or an unreachable cluster configuration.
+	ExitCodeError = 1
+
+	// ExitCodeFailed indicates that at least one check failed.
+	ExitCodeFailed = 2
+
+	// ExitCodeWarning indicates that no check failed, but at least one
+	// check produced a warning and warnings were configured to fail.
+	ExitCodeWarning = 3
+)
+
+// ExitError is returned by a command to request that the process exit
+// with the given code. It indicates a problem found by the checks
+// rather than an error in the tool, and should not be printed.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.Code)
+}
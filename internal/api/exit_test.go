@@ -0,0 +1,21 @@
+package api_test
+
+import (
+	"testing"
+
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func TestExitError(t *testing.T) {
+	t.Parallel()
+
+	err := xerrors.Errorf("run checks: %w", &api.ExitError{Code: api.ExitCodeFailed})
+
+	var exitErr *api.ExitError
+	assert.True(t, "wrapped error is an ExitError", xerrors.As(err, &exitErr))
+	assert.Equal(t, "exit code matches", api.ExitCodeFailed, exitErr.Code)
+	assert.Equal(t, "error message", "exit code 2", exitErr.Error())
+}
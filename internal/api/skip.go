@@ -4,7 +4,7 @@ package api
 func SkippedResult(name string, summary string, err error) *CheckResult {
 	details := make(map[string]interface{})
 	if err != nil {
-		details["error"] = err
+		details["error"] = NewError(err)
 	}
 	return &CheckResult{
 		Name:    name,
@@ -2,7 +2,9 @@ package api
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/fatih/color"
 	"golang.org/x/xerrors"
@@ -114,6 +116,78 @@ func (s CheckState) String() string {
 	panic(fmt.Sprintf("unknown state: %d", s))
 }
 
+// AllStates contains every known CheckState, in declaration order.
+var AllStates = []CheckState{StatePassed, StateWarning, StateFailed, StateInfo, StateSkipped}
+
+var (
+	_ = json.Marshaler(StatePassed)
+	_ = json.Unmarshaler((*CheckState)(nil))
+)
+
+// MarshalText encodes the state using its Text form, e.g. "PASS".
+func (s CheckState) MarshalText() ([]byte, error) {
+	text, err := s.Text()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(text), nil
+}
+
+// UnmarshalText decodes any state name accepted by ParseCheckState.
+func (s *CheckState) UnmarshalText(text []byte) error {
+	state, err := ParseCheckState(string(text))
+	if err != nil {
+		return err
+	}
+	*s = state
+	return nil
+}
+
+// MarshalJSON encodes the state as a JSON string using its Text form,
+// so that consumers do not depend on the numeric value of the state.
+func (s CheckState) MarshalJSON() ([]byte, error) {
+	text, err := s.Text()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(text)
+}
+
+// UnmarshalJSON decodes a state from a JSON string. For compatibility
+// with output from older versions, numeric states are also accepted.
+func (s *CheckState) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		return s.UnmarshalText([]byte(text))
+	}
+
+	var n int
+	if err := json.Unmarshal(data, &n); err != nil {
+		return xerrors.Errorf("state must be a string: %w", err)
+	}
+	state := CheckState(n)
+	if _, err := state.Text(); err != nil {
+		return err
+	}
+	*s = state
+	return nil
+}
+
+// ParseCheckState returns the CheckState with the given name. Names are
+// case-insensitive, and may be either the short form returned by Text
+// (e.g. "WARN") or the long form used by String without its "State"
+// prefix (e.g. "warning").
+func ParseCheckState(name string) (CheckState, error) {
+	for _, state := range AllStates {
+		if strings.EqualFold(name, state.MustText()) ||
+			strings.EqualFold(name, strings.TrimPrefix(state.String(), "State")) {
+			return state, nil
+		}
+	}
+
+	return 0, xerrors.Errorf("unknown state: %q", name)
+}
+
 type PrintFunc func(format string, args ...interface{}) string
 
 var _ PrintFunc = fmt.Sprintf
@@ -146,6 +220,13 @@ type CheckResult struct {
 	State   CheckState             `json:"state"`
 	Summary string                 `json:"summary"`
 	Details map[string]interface{} `json:"details,omitempty"`
+
+	// Remediation optionally describes the action to take to resolve a
+	// problem found by the check, such as a command to run.
+	Remediation string `json:"remediation,omitempty"`
+
+	// DocsURL optionally links to documentation relevant to the check.
+	DocsURL string `json:"docsUrl,omitempty"`
 }
 
 // CheckTarget indicates the subject of a Checker
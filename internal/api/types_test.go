@@ -1,6 +1,7 @@
 package api_test
 
 import (
+	"encoding/json"
 	"testing"
 
 	"cdr.dev/coder-doctor/internal/api"
@@ -44,3 +45,70 @@ func TestKnownStates(t *testing.T) {
 		})
 	}
 }
+
+func TestParseCheckState(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name     string
+		Expected api.CheckState
+	}{
+		{Name: "PASS", Expected: api.StatePassed},
+		{Name: "passed", Expected: api.StatePassed},
+		{Name: "warn", Expected: api.StateWarning},
+		{Name: "Warning", Expected: api.StateWarning},
+		{Name: "FAIL", Expected: api.StateFailed},
+		{Name: "failed", Expected: api.StateFailed},
+		{Name: "info", Expected: api.StateInfo},
+		{Name: "skip", Expected: api.StateSkipped},
+		{Name: "SKIPPED", Expected: api.StateSkipped},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			state, err := api.ParseCheckState(test.Name)
+			assert.Success(t, "parse state", err)
+			assert.Equal(t, "state matches", test.Expected, state)
+		})
+	}
+
+	_, err := api.ParseCheckState("bogus")
+	assert.ErrorContains(t, "unknown state", err, "unknown state")
+}
+
+func TestCheckStateJSON(t *testing.T) {
+	t.Parallel()
+
+	for _, state := range api.AllStates {
+		state := state
+		t.Run(state.String(), func(t *testing.T) {
+			t.Parallel()
+
+			out, err := json.Marshal(state)
+			assert.Success(t, "marshal state", err)
+			assert.Equal(t, "state marshals as text", `"`+state.MustText()+`"`, string(out))
+
+			var decoded api.CheckState
+			err = json.Unmarshal(out, &decoded)
+			assert.Success(t, "unmarshal state", err)
+			assert.Equal(t, "state round-trips", state, decoded)
+		})
+	}
+
+	var legacy api.CheckState
+	err := json.Unmarshal([]byte("2"), &legacy)
+	assert.Success(t, "unmarshal numeric state", err)
+	assert.Equal(t, "numeric state decoded", api.StateFailed, legacy)
+
+	err = json.Unmarshal([]byte("42"), &legacy)
+	assert.ErrorContains(t, "unknown numeric state", err, "unknown state")
+
+	err = json.Unmarshal([]byte(`"bogus"`), &legacy)
+	assert.ErrorContains(t, "unknown state name", err, "unknown state")
+
+	_, err = json.Marshal(api.CheckState(42))
+	assert.ErrorContains(t, "marshal unknown state", err, "unknown state")
+}
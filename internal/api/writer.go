@@ -1,5 +1,7 @@
 package api
 
+import "io"
+
 var _ = ResultWriter(&DiscardWriter{})
 
 // ResultWriter writes the given result to a configured output.
@@ -7,6 +9,24 @@ type ResultWriter interface {
 	WriteResult(*CheckResult) error
 }
 
+// ResultWriteCloser is a ResultWriter that must be closed after all
+// results have been written, for example because it buffers results
+// and only writes them to its output once the set is complete.
+type ResultWriteCloser interface {
+	ResultWriter
+	io.Closer
+}
+
+// CloseWriter closes the given writer if it implements io.Closer, and
+// is a no-op otherwise. Writers that wrap another ResultWriter should
+// use this to forward Close to the wrapped writer.
+func CloseWriter(writer ResultWriter) error {
+	if closer, ok := writer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
 // DiscardWriter is a writer that discards all results.
 type DiscardWriter struct {
 }
@@ -43,3 +43,25 @@ func TestCaptureWriter(t *testing.T) {
 	w.Clear()
 	assert.True(t, "empty buffer", w.Empty())
 }
+
+type closeCounter struct {
+	api.DiscardWriter
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestCloseWriter(t *testing.T) {
+	t.Parallel()
+
+	err := api.CloseWriter(&api.DiscardWriter{})
+	assert.Success(t, "close writer without Close", err)
+
+	w := &closeCounter{}
+	err = api.CloseWriter(w)
+	assert.Success(t, "close writer with Close", err)
+	assert.Equal(t, "Close called once", 1, w.closed)
+}
@@ -0,0 +1,238 @@
+package kube
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// helmReleaseSecretType is the type of the Secrets in which Helm 3 stores
+// each revision of a release.
+const helmReleaseSecretType corev1.SecretType = "helm.sh/release.v1"
+
+// coderChartName is the name of the Coder Helm chart.
+const coderChartName = "coder"
+
+// coderUpgradeDocsURL documents upgrading a Coder installation.
+const coderUpgradeDocsURL = "https://coder.com/docs/coder/latest/setup/upgrade"
+
+// helmRelease is the subset of a Helm release used by
+// CheckHelmReleases, which avoids depending on Helm.
+type helmRelease struct {
+	Name string `json:"name"`
+	Info struct {
+		Status       string `json:"status"`
+		LastDeployed string `json:"last_deployed"`
+		Description  string `json:"description"`
+	} `json:"info"`
+	Chart struct {
+		Metadata struct {
+			Name       string `json:"name"`
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+		} `json:"metadata"`
+	} `json:"chart"`
+	Revision int `json:"version"`
+}
+
+// decodeHelmRelease decodes the release stored in a Helm release Secret,
+// which is base64-encoded, gzipped JSON.
+func decodeHelmRelease(secret *corev1.Secret) (*helmRelease, error) {
+	data, ok := secret.Data["release"]
+	if !ok {
+		return nil, xerrors.New("secret has no release data")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, xerrors.Errorf("decode base64: %w", err)
+	}
+
+	// Helm compresses releases, but older versions did not.
+	if bytes.HasPrefix(b, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, xerrors.Errorf("open gzip: %w", err)
+		}
+		b, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, xerrors.Errorf("decompress: %w", err)
+		}
+	}
+
+	var rls helmRelease
+	if err := json.Unmarshal(b, &rls); err != nil {
+		return nil, xerrors.Errorf("decode json: %w", err)
+	}
+	return &rls, nil
+}
+
+// CheckHelmReleases finds existing installations of Coder from the Helm
+// release Secrets in the namespace, and checks that each can be upgraded
+// to the selected version of Coder. It returns one result per release.
+func (k *KubernetesChecker) CheckHelmReleases(ctx context.Context) []*api.CheckResult {
+	const checkName = "kubernetes-helm-release"
+
+	secrets, err := k.client.CoreV1().Secrets(k.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "owner=helm",
+		FieldSelector: "type=" + string(helmReleaseSecretType),
+	})
+	if apierrors.IsForbidden(err) {
+		result := api.SkippedResult(checkName, "cannot list Secrets, so existing Helm releases cannot be detected", err)
+		result.Details["namespace"] = k.namespace
+		result.Remediation = fmt.Sprintf("Run the checks as a user who can list secrets in namespace %s, or check for existing releases with: "+
+			"helm list --all --namespace=%s", k.namespace, k.namespace)
+		result.DocsURL = coderUpgradeDocsURL
+		return []*api.CheckResult{result}
+	}
+	if err != nil {
+		return []*api.CheckResult{api.ErrorResult(checkName, "failed to list helm release secrets", err)}
+	}
+
+	// Each revision of a release is stored separately, so keep the latest.
+	latest := make(map[string]*helmRelease)
+	var undecodable []*api.CheckResult
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if secret.Type != helmReleaseSecretType {
+			continue
+		}
+
+		rls, err := decodeHelmRelease(secret)
+		if err != nil {
+			// The release may be an installation of Coder, so it cannot
+			// be ignored.
+			result := api.WarnResult(checkName,
+				fmt.Sprintf("unable to decode Helm release Secret %q, so it cannot be checked for a Coder installation", secret.Name))
+			result.Details = map[string]interface{}{
+				"namespace": k.namespace,
+				"secret":    secret.Name,
+				"error":     api.NewError(err),
+			}
+			result.Remediation = fmt.Sprintf("Check the release stored in the Secret with: helm list --all --namespace=%s", k.namespace)
+			result.DocsURL = coderUpgradeDocsURL
+			undecodable = append(undecodable, result)
+			continue
+		}
+		if rls.Chart.Metadata.Name != coderChartName {
+			continue
+		}
+		if prev, ok := latest[rls.Name]; !ok || rls.Revision > prev.Revision {
+			latest[rls.Name] = rls
+		}
+	}
+
+	if len(latest) == 0 {
+		if len(undecodable) > 0 {
+			return undecodable
+		}
+		result := api.PassResult(checkName, fmt.Sprintf("no existing Coder installation found in namespace %q", k.namespace))
+		result.Details["namespace"] = k.namespace
+		return []*api.CheckResult{result}
+	}
+
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]*api.CheckResult, 0, len(names))
+	for _, name := range names {
+		results = append(results, k.checkHelmRelease(checkName, latest[name]))
+	}
+	return append(results, undecodable...)
+}
+
+// checkHelmRelease reports an installed Coder release, and whether it can
+// be upgraded to the selected version of Coder.
+func (k *KubernetesChecker) checkHelmRelease(checkName string, rls *helmRelease) *api.CheckResult {
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"namespace":     k.namespace,
+			"release":       rls.Name,
+			"revision":      rls.Revision,
+			"status":        rls.Info.Status,
+			"chart-version": rls.Chart.Metadata.Version,
+			"app-version":   rls.Chart.Metadata.AppVersion,
+			"last-deployed": rls.Info.LastDeployed,
+			"coder-version": k.coderVersion.String(),
+		},
+	}
+	installed := fmt.Sprintf("release %q (chart %s-%s, revision %d, %s)",
+		rls.Name, rls.Chart.Metadata.Name, rls.Chart.Metadata.Version, rls.Revision, rls.Info.Status)
+
+	switch rls.Info.Status {
+	case "uninstalled", "uninstalling":
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("%s has been uninstalled", installed)
+		return result
+	case "pending-install", "pending-upgrade", "pending-rollback":
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("%s has an operation in progress, so Helm will refuse to upgrade it", installed)
+		result.Remediation = fmt.Sprintf("Wait for the operation to finish, or if it was interrupted, roll back with: helm rollback --namespace=%s %s",
+			k.namespace, rls.Name)
+		result.DocsURL = coderUpgradeDocsURL
+		return result
+	}
+
+	version := rls.Chart.Metadata.AppVersion
+	if version == "" {
+		version = rls.Chart.Metadata.Version
+	}
+	current, err := semver.NewVersion(version)
+	if err != nil {
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("%s has unrecognized version %q, so the upgrade path cannot be checked", installed, version)
+		result.Details["error"] = api.NewError(err)
+		return result
+	}
+
+	var problem string
+	state := api.StatePassed
+	switch {
+	case current.Major() != k.coderVersion.Major():
+		state = api.StateFailed
+		problem = fmt.Sprintf("upgrading from Coder %s to %s crosses a major version, which is not supported in place", current, k.coderVersion)
+	case current.Minor() > k.coderVersion.Minor():
+		state = api.StateFailed
+		problem = fmt.Sprintf("Coder %s is newer than %s, and downgrades are not supported", current, k.coderVersion)
+	case k.coderVersion.Minor()-current.Minor() > 1:
+		state = api.StateWarning
+		problem = fmt.Sprintf("upgrading from Coder %s to %s skips minor versions, so upgrade one minor version at a time", current, k.coderVersion)
+	}
+
+	if rls.Info.Status == "failed" && state == api.StatePassed {
+		state = api.StateWarning
+		problem = fmt.Sprintf("the last operation failed: %s", rls.Info.Description)
+	}
+
+	result.State = state
+	if problem == "" {
+		if current.Minor() == k.coderVersion.Minor() {
+			result.Summary = fmt.Sprintf("%s already runs Coder %s", installed, current)
+		} else {
+			result.Summary = fmt.Sprintf("%s can be upgraded from Coder %s to %s", installed, current, k.coderVersion)
+		}
+		return result
+	}
+
+	result.Summary = fmt.Sprintf("%s: %s", installed, problem)
+	result.Remediation = fmt.Sprintf("Review the upgrade instructions, and the release with: helm history --namespace=%s %s", k.namespace, rls.Name)
+	result.DocsURL = coderUpgradeDocsURL
+	return result
+}
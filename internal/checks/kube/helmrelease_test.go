@@ -0,0 +1,254 @@
+package kube
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckHelmReleases(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name         string
+		Objects      []runtime.Object
+		CoderVersion string
+		F            func(*testing.T, []*api.CheckResult)
+	}{
+		{
+			Name: "no releases",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "ingress-nginx", "ingress-nginx", "4.0.1", "1.0.0", 1, "deployed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "one result", 1, len(results))
+				assert.Equal(t, "should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "summary", `no existing Coder installation found in namespace "default"`, results[0].Summary)
+			},
+		},
+		{
+			Name: "latest revision",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.19.0", "1.19.0", 1, "superseded"),
+				helmReleaseSecret(t, "coder", "coder", "1.20.1", "1.20.1", 2, "deployed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "one result", 1, len(results))
+				assert.Equal(t, "should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "summary", `release "coder" (chart coder-1.20.1, revision 2, deployed) can be upgraded from Coder 1.20.1 to 1.21.0`, results[0].Summary)
+				assert.Equal(t, "app version", "1.20.1", results[0].Details["app-version"])
+				assert.Equal(t, "revision", 2, results[0].Details["revision"])
+			},
+		},
+		{
+			Name: "same version",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.21.2", "1.21.2", 4, "deployed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "summary", `release "coder" (chart coder-1.21.2, revision 4, deployed) already runs Coder 1.21.2`, results[0].Summary)
+			},
+		},
+		{
+			Name: "skips minor versions",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.19.0", "1.19.0", 1, "deployed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, results[0].State)
+				assert.Equal(t, "docs", coderUpgradeDocsURL, results[0].DocsURL)
+			},
+		},
+		{
+			Name: "downgrade",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.21.0", "1.21.0", 1, "deployed"),
+			},
+			CoderVersion: "1.20.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, results[0].State)
+				assert.Equal(t, "summary", `release "coder" (chart coder-1.21.0, revision 1, deployed): Coder 1.21.0 is newer than 1.20.0, and downgrades are not supported`, results[0].Summary)
+			},
+		},
+		{
+			Name: "pending upgrade",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.20.0", "1.20.0", 3, "pending-upgrade"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, results[0].State)
+				assert.Equal(t, "remediation", "Wait for the operation to finish, or if it was interrupted, roll back with: helm rollback --namespace=default coder", results[0].Remediation)
+			},
+		},
+		{
+			Name: "failed",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.21.0", "1.21.0", 2, "failed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, results[0].State)
+				assert.Equal(t, "summary", `release "coder" (chart coder-1.21.0, revision 2, failed): the last operation failed: Upgrade "coder" failed`, results[0].Summary)
+			},
+		},
+		{
+			Name: "multiple releases",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder-b", "coder", "1.21.0", "1.21.0", 1, "deployed"),
+				helmReleaseSecret(t, "coder-a", "coder", "1.20.0", "1.20.0", 1, "deployed"),
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "two results", 2, len(results))
+				assert.Equal(t, "first", "coder-a", results[0].Details["release"])
+				assert.Equal(t, "second", "coder-b", results[1].Details["release"])
+			},
+		},
+		{
+			Name: "undecodable",
+			Objects: []runtime.Object{
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sh.helm.release.v1.coder.v1", Labels: map[string]string{"owner": "helm"}},
+					Type:       helmReleaseSecretType,
+					Data:       map[string][]byte{"release": []byte("not base64!")},
+				},
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "one result", 1, len(results))
+				assert.Equal(t, "should warn", api.StateWarning, results[0].State)
+				assert.Equal(t, "secret", "sh.helm.release.v1.coder.v1", results[0].Details["secret"])
+				assert.True(t, "remediation", results[0].Remediation != "")
+			},
+		},
+		{
+			Name: "undecodable with release",
+			Objects: []runtime.Object{
+				helmReleaseSecret(t, "coder", "coder", "1.21.0", "1.21.0", 1, "deployed"),
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "sh.helm.release.v1.other.v1", Labels: map[string]string{"owner": "helm"}},
+					Type:       helmReleaseSecretType,
+					Data:       map[string][]byte{"release": []byte("not base64!")},
+				},
+			},
+			CoderVersion: "1.21.0",
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "two results", 2, len(results))
+				assert.Equal(t, "should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "should warn", api.StateWarning, results[1].State)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client, WithCoderVersion(semver.MustParse(test.CoderVersion)))
+			test.F(t, checker.CheckHelmReleases(context.Background()))
+		})
+	}
+}
+
+func Test_CheckHelmReleases_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("list secrets", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		results := NewKubernetesChecker(client).CheckHelmReleases(context.Background())
+		assert.Equal(t, "one result", 1, len(results))
+		assert.Equal(t, "should fail", api.StateFailed, results[0].State)
+		assert.ErrorContains(t, "error", results[0].Details["error"].(error), "ouch")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "secrets"}, "", xerrors.New("RBAC denied"))
+		})
+
+		results := NewKubernetesChecker(client).CheckHelmReleases(context.Background())
+		assert.Equal(t, "one result", 1, len(results))
+		assert.Equal(t, "should be skipped", api.StateSkipped, results[0].State)
+		assert.Equal(t, "summary", "cannot list Secrets, so existing Helm releases cannot be detected", results[0].Summary)
+		assert.True(t, "remediation", results[0].Remediation != "")
+	})
+}
+
+func helmReleaseSecret(t *testing.T, name, chart, chartVersion, appVersion string, revision int, status string) *corev1.Secret {
+	t.Helper()
+
+	rls := map[string]interface{}{
+		"name":      name,
+		"namespace": "default",
+		"version":   revision,
+		"info": map[string]interface{}{
+			"status":      status,
+			"description": fmt.Sprintf("Upgrade %q failed", name),
+		},
+		"chart": map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name":       chart,
+				"version":    chartVersion,
+				"appVersion": appVersion,
+			},
+		},
+	}
+	b, err := json.Marshal(rls)
+	assert.Success(t, "marshal release", err)
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, err = zw.Write(b)
+	assert.Success(t, "compress release", err)
+	assert.Success(t, "close gzip", zw.Close())
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      fmt.Sprintf("sh.helm.release.v1.%s.v%d", name, revision),
+			Labels: map[string]string{
+				"owner":   "helm",
+				"name":    name,
+				"status":  status,
+				"version": fmt.Sprint(revision),
+			},
+		},
+		Type: helmReleaseSecretType,
+		Data: map[string][]byte{"release": []byte(base64.StdEncoding.EncodeToString(buf.Bytes()))},
+	}
+}
@@ -0,0 +1,163 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// defaultIngressClassAnnotation marks the IngressClass assigned to Ingresses
+// that do not specify one.
+const defaultIngressClassAnnotation = "ingressclass.kubernetes.io/is-default-class"
+
+// ingressControllersDocsURL lists Ingress controllers that can be installed.
+const ingressControllersDocsURL = "https://kubernetes.io/docs/concepts/services-networking/ingress-controllers/"
+
+// knownIngressControllers maps prefixes of IngressClass controller strings
+// to the name of the controller implementing them. Entries are checked in
+// order, so longer prefixes must be listed first.
+var knownIngressControllers = []struct {
+	Prefix string
+	Name   string
+}{
+	{Prefix: "k8s.io/ingress-nginx", Name: "ingress-nginx"},
+	{Prefix: "nginx.org/ingress-controller", Name: "NGINX Ingress Controller"},
+	{Prefix: "traefik.io/ingress-controller", Name: "Traefik"},
+	{Prefix: "projectcontour.io/", Name: "Contour"},
+	{Prefix: "k8s.io/ingress-gce", Name: "GKE Ingress"},
+	{Prefix: "networking.gke.io/", Name: "GKE Ingress"},
+	{Prefix: "ingress.k8s.aws/alb", Name: "AWS Load Balancer Controller"},
+}
+
+// ingressControllerName returns the name of the known controller for the
+// given controller string, or an empty string if it is not recognized.
+func ingressControllerName(controller string) string {
+	for _, known := range knownIngressControllers {
+		if strings.HasPrefix(controller, known.Prefix) {
+			return known.Name
+		}
+	}
+	return ""
+}
+
+// ingressClassSummary describes an IngressClass and its controller.
+type ingressClassSummary struct {
+	Name       string `json:"name"`
+	Controller string `json:"controller"`
+	// Implementation is the name of the recognized controller, if any.
+	Implementation string `json:"implementation,omitempty"`
+	Default        bool   `json:"default"`
+}
+
+// describe returns the name of the controller serving the class.
+func (s ingressClassSummary) describe() string {
+	if s.Implementation == "" {
+		return fmt.Sprintf("controller %s", s.Controller)
+	}
+	return fmt.Sprintf("%s (%s)", s.Implementation, s.Controller)
+}
+
+// CheckIngress checks that an IngressClass exists to serve the Ingress
+// created by Coder, and that at most one is marked as the default.
+func (k *KubernetesChecker) CheckIngress(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-ingress"
+
+	classes, err := k.client.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return &api.CheckResult{
+			Name:        checkName,
+			State:       api.StateWarning,
+			Summary:     "cluster does not support networking.k8s.io/v1 IngressClasses, so the Ingress controller cannot be detected",
+			Remediation: "Check that an Ingress controller is installed",
+			DocsURL:     ingressControllersDocsURL,
+		}
+	}
+	if apierrors.IsForbidden(err) {
+		// IngressClasses are cluster-scoped, so namespace-scoped users may
+		// not be able to list them.
+		result := api.SkippedResult(checkName, "unable to detect the Ingress controller without permission to list ingressclasses", err)
+		result.Remediation = "Run the checks as a user who can list ingressclasses, such as a cluster administrator, " +
+			"or check that an Ingress controller is installed with: kubectl get ingressclasses"
+		result.DocsURL = ingressControllersDocsURL
+		return result
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list ingress classes", err)
+	}
+
+	summaries := make([]ingressClassSummary, 0, len(classes.Items))
+	var defaults []ingressClassSummary
+	for _, class := range classes.Items {
+		summary := ingressClassSummary{
+			Name:           class.Name,
+			Controller:     class.Spec.Controller,
+			Implementation: ingressControllerName(class.Spec.Controller),
+			Default:        isDefaultIngressClass(&class),
+		}
+		summaries = append(summaries, summary)
+		if summary.Default {
+			defaults = append(defaults, summary)
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+	sort.Slice(defaults, func(i, j int) bool {
+		return defaults[i].Name < defaults[j].Name
+	})
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"ingress-classes": summaries,
+		},
+	}
+
+	switch {
+	case len(summaries) == 0:
+		result.State = api.StateWarning
+		result.Summary = "cluster has no IngressClasses, so the Ingress created by Coder will not be served"
+		result.Remediation = "Install an Ingress controller, such as ingress-nginx, or disable the Ingress in the Coder Helm chart"
+		result.DocsURL = ingressControllersDocsURL
+	case len(defaults) > 1:
+		names := make([]string, 0, len(defaults))
+		for _, d := range defaults {
+			names = append(names, d.Name)
+		}
+
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("cluster has %d default IngressClasses (%s), so Ingresses without a class will be rejected",
+			len(defaults), strings.Join(names, ", "))
+		result.Remediation = fmt.Sprintf("Remove the default annotation from all but one IngressClass with: kubectl annotate ingressclass <name> %s-",
+			defaultIngressClassAnnotation)
+		result.DocsURL = "https://kubernetes.io/docs/concepts/services-networking/ingress/#default-ingress-class"
+	case len(defaults) == 1:
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("default IngressClass %q is served by %s", defaults[0].Name, defaults[0].describe())
+		result.Details["default-ingress-class"] = defaults[0].Name
+	default:
+		names := make([]string, 0, len(summaries))
+		for _, s := range summaries {
+			names = append(names, fmt.Sprintf("%s: %s", s.Name, s.describe()))
+		}
+
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("cluster has no default IngressClass, so the Ingress created by Coder must specify one of: %s",
+			strings.Join(names, "; "))
+	}
+
+	return result
+}
+
+// isDefaultIngressClass returns true if the IngressClass is annotated as
+// the default.
+func isDefaultIngressClass(class *networkingv1.IngressClass) bool {
+	return class.Annotations[defaultIngressClassAnnotation] == "true"
+}
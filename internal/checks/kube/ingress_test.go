@@ -0,0 +1,162 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckIngress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Objects []runtime.Object
+		F       func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name:    "no ingress classes",
+			Objects: nil,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", "cluster has no IngressClasses, so the Ingress created by Coder will not be served", result.Summary)
+				assert.Equal(t, "docs", ingressControllersDocsURL, result.DocsURL)
+			},
+		},
+		{
+			Name: "one default",
+			Objects: []runtime.Object{
+				ingressClass("nginx", "k8s.io/ingress-nginx", true),
+				ingressClass("alb", "ingress.k8s.aws/alb", false),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `default IngressClass "nginx" is served by ingress-nginx (k8s.io/ingress-nginx)`, result.Summary)
+				assert.Equal(t, "default", "nginx", result.Details["default-ingress-class"])
+				assert.Equal(t, "classes", []ingressClassSummary{
+					{Name: "alb", Controller: "ingress.k8s.aws/alb", Implementation: "AWS Load Balancer Controller"},
+					{Name: "nginx", Controller: "k8s.io/ingress-nginx", Implementation: "ingress-nginx", Default: true},
+				}, result.Details["ingress-classes"])
+			},
+		},
+		{
+			Name: "unknown default controller",
+			Objects: []runtime.Object{
+				ingressClass("custom", "example.com/ingress", true),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `default IngressClass "custom" is served by controller example.com/ingress`, result.Summary)
+			},
+		},
+		{
+			Name: "no default",
+			Objects: []runtime.Object{
+				ingressClass("traefik", "traefik.io/ingress-controller", false),
+				ingressClass("contour", "projectcontour.io/projectcontour/contour", false),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", "cluster has no default IngressClass, so the Ingress created by Coder must specify one of: "+
+					"contour: Contour (projectcontour.io/projectcontour/contour); traefik: Traefik (traefik.io/ingress-controller)", result.Summary)
+			},
+		},
+		{
+			Name: "multiple defaults",
+			Objects: []runtime.Object{
+				ingressClass("nginx", "k8s.io/ingress-nginx", true),
+				ingressClass("gce", "k8s.io/ingress-gce", true),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", "cluster has 2 default IngressClasses (gce, nginx), so Ingresses without a class will be rejected", result.Summary)
+				assert.True(t, "should have a remediation", result.Remediation != "")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckIngress(context.Background()))
+		})
+	}
+}
+
+func Test_CheckIngress_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not supported", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "ingressclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "networking.k8s.io", Resource: "ingressclasses"}, "")
+		})
+
+		result := NewKubernetesChecker(client).CheckIngress(context.Background())
+		assert.Equal(t, "should warn", api.StateWarning, result.State)
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "ingressclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "networking.k8s.io", Resource: "ingressclasses"}, "", xerrors.New("RBAC denied"))
+		})
+
+		result := NewKubernetesChecker(client).CheckIngress(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", "unable to detect the Ingress controller without permission to list ingressclasses", result.Summary)
+		assert.True(t, "remediation", result.Remediation != "")
+	})
+
+	t.Run("list ingress classes", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "ingressclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckIngress(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "ouch")
+	})
+}
+
+func Test_ingressControllerName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "nginx inc", "NGINX Ingress Controller", ingressControllerName("nginx.org/ingress-controller"))
+	assert.Equal(t, "gke", "GKE Ingress", ingressControllerName("networking.gke.io/ingress-gce"))
+	assert.Equal(t, "unknown", "", ingressControllerName("example.com/ingress"))
+}
+
+func ingressClass(name, controller string, isDefault bool) *networkingv1.IngressClass {
+	class := &networkingv1.IngressClass{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       networkingv1.IngressClassSpec{Controller: controller},
+	}
+	if isDefault {
+		class.Annotations = map[string]string{defaultIngressClassAnnotation: "true"}
+	}
+	return class
+}
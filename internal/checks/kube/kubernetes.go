@@ -2,27 +2,58 @@ package kube
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"golang.org/x/xerrors"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/client-go/kubernetes"
 
 	"cdr.dev/slog"
 	"cdr.dev/slog/sloggers/sloghuman"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 )
 
 var _ = api.Checker(&KubernetesChecker{})
 
+// DefaultConcurrency is the default number of concurrent requests used
+// when checking RBAC permissions one at a time.
+const DefaultConcurrency = 8
+
 type KubernetesChecker struct {
 	namespace    string
 	client       kubernetes.Interface
 	writer       api.ResultWriter
 	coderVersion *semver.Version
 	log          slog.Logger
+	requirements *requirements.Manifest
+	versionReqs  []CoderVersionRequirement
 	reqs         *VersionedResourceRequirements
+	nodeReqs     *VersionedNodeRequirements
+	rbacGaps     []rbacGap
+	concurrency  int
+	workspaces   int
+
+	// subject, if set, is the subject whose RBAC permissions are checked
+	// instead of those of the user running the checks.
+	subject       *rbacv1.Subject
+	subjectUser   string
+	subjectGroups []string
+
+	// smokeTests enables checks that create resources in the namespace.
+	smokeTests        bool
+	smokeTestTimeout  time.Duration
+	smokeTestInterval time.Duration
+
+	// loadBalancerSmokeTest enables the smoke test that creates a
+	// LoadBalancer Service, which may provision a billable, publicly
+	// reachable load balancer.
+	loadBalancerSmokeTest        bool
+	loadBalancerSmokeTestTimeout time.Duration
 }
 
 type Option func(k *KubernetesChecker)
@@ -35,13 +66,23 @@ func NewKubernetesChecker(client kubernetes.Interface, opts ...Option) *Kubernet
 		writer:    &api.DiscardWriter{},
 		// Select the newest version by default
 		coderVersion: semver.MustParse("100.0.0"),
+		concurrency:  DefaultConcurrency,
+		workspaces:   DefaultWorkspaces,
+		requirements: requirements.Default(),
+
+		smokeTestTimeout:  DefaultSmokeTestTimeout,
+		smokeTestInterval: smokeTestPollInterval,
+
+		loadBalancerSmokeTestTimeout: DefaultLoadBalancerSmokeTestTimeout,
 	}
 
 	for _, opt := range opts {
 		opt(checker)
 	}
 
-	checker.reqs = findClosestVersionRequirements(checker.coderVersion)
+	checker.versionReqs = versionRequirementsFromManifest(checker.requirements)
+	checker.reqs = findClosestVersionRequirements(resourceRequirementsFromManifest(checker.requirements), checker.coderVersion)
+	checker.nodeReqs = findClosestNodeRequirements(nodeRequirementsFromManifest(checker.requirements), checker.coderVersion)
 
 	if err := checker.Validate(); err != nil {
 		panic(xerrors.Errorf("error validating kube checker: %w", err))
@@ -74,10 +115,113 @@ func WithNamespace(ns string) Option {
 	}
 }
 
+// WithRequirements sets the requirements checked against, in place of
+// the defaults embedded in the binary.
+func WithRequirements(m *requirements.Manifest) Option {
+	return func(k *KubernetesChecker) {
+		k.requirements = m
+	}
+}
+
+// WithConcurrency sets the maximum number of concurrent requests used
+// when checking RBAC permissions one at a time. Requests are still
+// subject to the rate limits configured for the client.
+func WithConcurrency(n int) Option {
+	return func(k *KubernetesChecker) {
+		k.concurrency = n
+	}
+}
+
+// WithWorkspaces sets the number of workspaces the namespace's
+// ResourceQuotas are expected to fit, in addition to the control plane.
+func WithWorkspaces(n int) Option {
+	return func(k *KubernetesChecker) {
+		k.workspaces = n
+	}
+}
+
+// WithSubject checks the RBAC permissions of the given user and groups,
+// rather than those of the user running the checks. This requires
+// permission to create SubjectAccessReviews. As the API server does for
+// every authenticated request, the subject is added to the
+// system:authenticated group.
+func WithSubject(user string, groups []string) Option {
+	return func(k *KubernetesChecker) {
+		k.subject = &rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: user}
+		k.subjectUser = user
+		k.subjectGroups = append([]string(nil), groups...)
+		for _, group := range groups {
+			if group == "system:authenticated" {
+				return
+			}
+		}
+		k.subjectGroups = append(k.subjectGroups, "system:authenticated")
+	}
+}
+
+// WithServiceAccount checks the RBAC permissions of the given
+// ServiceAccount, such as the one Coder will run as, rather than those
+// of the user running the checks. This requires permission to create
+// SubjectAccessReviews.
+func WithServiceAccount(namespace, name string) Option {
+	return func(k *KubernetesChecker) {
+		k.subject = &rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: namespace, Name: name}
+		k.subjectUser = fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+		k.subjectGroups = []string{
+			"system:serviceaccounts",
+			"system:serviceaccounts:" + namespace,
+			"system:authenticated",
+		}
+	}
+}
+
+// WithSmokeTests enables smoke tests, which create a PersistentVolumeClaim
+// and a pod in the namespace, and wait up to the given timeout for them to
+// become ready. The resources are deleted once the tests complete.
+func WithSmokeTests(timeout time.Duration) Option {
+	return func(k *KubernetesChecker) {
+		k.smokeTests = true
+		k.smokeTestTimeout = timeout
+	}
+}
+
+// WithLoadBalancerSmokeTest enables the smoke test which creates a
+// LoadBalancer Service in the namespace, and waits up to the given timeout
+// for a load balancer to be provisioned. It is separate from
+// WithSmokeTests, since cloud load balancers are billed and publicly
+// reachable.
+func WithLoadBalancerSmokeTest(timeout time.Duration) Option {
+	return func(k *KubernetesChecker) {
+		k.loadBalancerSmokeTest = true
+		k.loadBalancerSmokeTestTimeout = timeout
+	}
+}
+
+// Subject returns the subject whose RBAC permissions are checked, if
+// configured with WithSubject or WithServiceAccount.
+func (k *KubernetesChecker) Subject() (rbacv1.Subject, bool) {
+	if k.subject == nil {
+		return rbacv1.Subject{}, false
+	}
+	return *k.subject, true
+}
+
 func (k *KubernetesChecker) Validate() error {
-	if k.reqs == nil {
+	if k.reqs == nil || findNearestVersion(k.versionReqs, k.coderVersion) == nil {
 		return xerrors.Errorf("unhandled coder version: %s", k.coderVersion.String())
 	}
+	if k.concurrency < 1 {
+		return xerrors.Errorf("concurrency must be at least 1, got %d", k.concurrency)
+	}
+	if k.workspaces < 0 {
+		return xerrors.Errorf("workspaces must not be negative, got %d", k.workspaces)
+	}
+	if k.smokeTests && k.smokeTestTimeout <= 0 {
+		return xerrors.Errorf("smoke test timeout must be positive, got %s", k.smokeTestTimeout)
+	}
+	if k.loadBalancerSmokeTest && k.loadBalancerSmokeTestTimeout <= 0 {
+		return xerrors.Errorf("load balancer smoke test timeout must be positive, got %s", k.loadBalancerSmokeTestTimeout)
+	}
 	return nil
 }
 
@@ -87,16 +231,68 @@ func (k *KubernetesChecker) Run(ctx context.Context) error {
 		return xerrors.Errorf("check version: %w", err)
 	}
 
+	if err := k.writer.WriteResult(k.CheckNamespace(ctx)); err != nil {
+		return xerrors.Errorf("check namespace: %w", err)
+	}
+
+	for _, res := range k.CheckHelmReleases(ctx) {
+		if err := k.writer.WriteResult(res); err != nil {
+			return xerrors.Errorf("check helm releases: %w", err)
+		}
+	}
+
 	for _, res := range k.CheckResources(ctx) {
 		if err := k.writer.WriteResult(res); err != nil {
 			return xerrors.Errorf("check api resources: %w", err)
 		}
 	}
 
+	if err := k.writer.WriteResult(k.CheckMetrics(ctx)); err != nil {
+		return xerrors.Errorf("check metrics: %w", err)
+	}
+
 	for _, res := range k.CheckRBAC(ctx) {
 		if err := k.writer.WriteResult(res); err != nil {
 			return xerrors.Errorf("check RBAC: %w", err)
 		}
 	}
+
+	if err := k.writer.WriteResult(k.CheckStorage(ctx)); err != nil {
+		return xerrors.Errorf("check storage: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckNodes(ctx)); err != nil {
+		return xerrors.Errorf("check nodes: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckIngress(ctx)); err != nil {
+		return xerrors.Errorf("check ingress: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckNetworkPolicy(ctx)); err != nil {
+		return xerrors.Errorf("check network policy: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckPodSecurity(ctx)); err != nil {
+		return xerrors.Errorf("check pod security: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckPodSecurityPolicy(ctx)); err != nil {
+		return xerrors.Errorf("check pod security policy: %w", err)
+	}
+
+	if err := k.writer.WriteResult(k.CheckResourceQuota(ctx)); err != nil {
+		return xerrors.Errorf("check resource quota: %w", err)
+	}
+
+	if !k.smokeTests && !k.loadBalancerSmokeTest {
+		return nil
+	}
+
+	for _, res := range k.CheckSmokeTests(ctx) {
+		if err := k.writer.WriteResult(res); err != nil {
+			return xerrors.Errorf("smoke tests: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,157 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/xerrors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/rest"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// metricsAPIService is the name of the aggregated APIService serving the
+// metrics.k8s.io API.
+const metricsAPIService = "v1beta1.metrics.k8s.io"
+
+// apiService is the subset of an apiregistration.k8s.io/v1 APIService used
+// by CheckMetrics, which avoids depending on the aggregator's clientset.
+type apiService struct {
+	Spec struct {
+		// Service is nil for APIs served by the API server itself.
+		Service *struct {
+			Namespace string `json:"namespace"`
+			Name      string `json:"name"`
+		} `json:"service"`
+	} `json:"spec"`
+	Status struct {
+		Conditions []struct {
+			Type    string `json:"type"`
+			Status  string `json:"status"`
+			Reason  string `json:"reason"`
+			Message string `json:"message"`
+		} `json:"conditions"`
+	} `json:"status"`
+}
+
+// podMetricsList is the subset of a metrics.k8s.io/v1beta1 PodMetricsList
+// used by CheckMetrics.
+type podMetricsList struct {
+	Items []struct {
+		Metadata struct {
+			Name string `json:"name"`
+		} `json:"metadata"`
+	} `json:"items"`
+}
+
+// CheckMetrics checks that the metrics.k8s.io API, which Coder uses to
+// report the resource usage of workspaces, is registered, available, and
+// returns PodMetrics for the namespace.
+func (k *KubernetesChecker) CheckMetrics(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-metrics"
+
+	rc := k.client.Discovery().RESTClient()
+	if rc == nil {
+		return api.SkippedResult(checkName, "unable to query the metrics API", nil)
+	}
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"apiservice": metricsAPIService,
+			"namespace":  k.namespace,
+		},
+	}
+
+	svc, err := getAPIService(ctx, rc, metricsAPIService)
+	switch {
+	case apierrors.IsNotFound(err):
+		result.State = api.StateFailed
+		result.Summary = "metrics API is not installed, so workspace resource usage will not be shown"
+		result.Remediation = "Install metrics-server in the cluster to provide the metrics.k8s.io API"
+		result.DocsURL = metricsServerURL
+		return result
+	case apierrors.IsForbidden(err):
+		// Reading APIServices requires cluster-wide permissions, which are
+		// not needed to install Coder, so fall back to querying metrics.
+		result.Details["available"] = "unknown"
+	case err != nil:
+		return api.ErrorResult(checkName, fmt.Sprintf("failed to get apiservice %q", metricsAPIService), err)
+	default:
+		if svc.Spec.Service != nil {
+			result.Details["service"] = fmt.Sprintf("%s/%s", svc.Spec.Service.Namespace, svc.Spec.Service.Name)
+		}
+
+		available := "Unknown"
+		var reason, message string
+		for _, cond := range svc.Status.Conditions {
+			if cond.Type == "Available" {
+				available, reason, message = cond.Status, cond.Reason, cond.Message
+			}
+		}
+		result.Details["available"] = available
+
+		if available != "True" {
+			result.State = api.StateFailed
+			result.Summary = fmt.Sprintf("metrics API is installed but unavailable (%s)", metricsUnavailableReason(reason, message))
+			result.Remediation = "Check that the metrics-server pods are running, and that the API server can reach them"
+			if svc.Spec.Service != nil {
+				result.Remediation = fmt.Sprintf("Check that the metrics-server pods are running, and that the API server can reach them, with:\n"+
+					"kubectl describe apiservice %s\n"+
+					"kubectl get endpoints --namespace=%s %s",
+					metricsAPIService, svc.Spec.Service.Namespace, svc.Spec.Service.Name)
+			}
+			result.DocsURL = metricsServerURL
+			return result
+		}
+	}
+
+	var metrics podMetricsList
+	raw, err := rc.Get().AbsPath("/apis/metrics.k8s.io/v1beta1/namespaces", k.namespace, "pods").DoRaw(ctx)
+	if err == nil {
+		err = json.Unmarshal(raw, &metrics)
+	}
+	if err != nil {
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("failed to get pod metrics in namespace %q: %s", k.namespace, err)
+		result.Details["error"] = api.NewError(err)
+		result.Remediation = fmt.Sprintf("Check the metrics-server logs, and that pod metrics are returned by:\n"+
+			"kubectl top pods --namespace=%s", k.namespace)
+		result.DocsURL = metricsServerURL
+		return result
+	}
+
+	result.Details["pods"] = len(metrics.Items)
+	result.State = api.StatePassed
+	result.Summary = fmt.Sprintf("metrics API is available, and reports metrics for %d pods in namespace %q", len(metrics.Items), k.namespace)
+	return result
+}
+
+// getAPIService fetches the named APIService.
+func getAPIService(ctx context.Context, rc rest.Interface, name string) (*apiService, error) {
+	raw, err := rc.Get().AbsPath("/apis/apiregistration.k8s.io/v1/apiservices", name).DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var svc apiService
+	if err := json.Unmarshal(raw, &svc); err != nil {
+		return nil, xerrors.Errorf("decode apiservice: %w", err)
+	}
+	return &svc, nil
+}
+
+// metricsUnavailableReason describes why an APIService is not available.
+func metricsUnavailableReason(reason, message string) string {
+	switch {
+	case reason != "" && message != "":
+		return fmt.Sprintf("%s: %s", reason, message)
+	case reason != "":
+		return reason
+	case message != "":
+		return message
+	}
+	return "no reason given"
+}
@@ -0,0 +1,166 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckMetrics(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name string
+		// Responses maps request paths to status codes and bodies. Other
+		// paths return 404.
+		Responses map[string]testResponse
+		F         func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name: "not installed",
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "metrics API is not installed, so workspace resource usage will not be shown", result.Summary)
+				assert.Equal(t, "docs", metricsServerURL, result.DocsURL)
+			},
+		},
+		{
+			Name: "unavailable",
+			Responses: map[string]testResponse{
+				metricsAPIServicePath: {http.StatusOK, metricsAPIServiceWithStatus("False", "FailedDiscoveryCheck", "failing or missing response")},
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "metrics API is installed but unavailable (FailedDiscoveryCheck: failing or missing response)", result.Summary)
+				assert.Equal(t, "service", "kube-system/metrics-server", result.Details["service"])
+				assert.Equal(t, "remediation", "Check that the metrics-server pods are running, and that the API server can reach them, with:\n"+
+					"kubectl describe apiservice v1beta1.metrics.k8s.io\n"+
+					"kubectl get endpoints --namespace=kube-system metrics-server", result.Remediation)
+			},
+		},
+		{
+			Name: "pod metrics error",
+			Responses: map[string]testResponse{
+				metricsAPIServicePath: {http.StatusOK, metricsAPIServiceWithStatus("True", "Passed", "all checks passed")},
+				podMetricsPath:        {http.StatusServiceUnavailable, nil},
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.ErrorContains(t, "error", result.Details["error"].(error), "the server is currently unable to handle the request")
+				assert.Equal(t, "remediation", "Check the metrics-server logs, and that pod metrics are returned by:\nkubectl top pods --namespace=default", result.Remediation)
+			},
+		},
+		{
+			Name: "working",
+			Responses: map[string]testResponse{
+				metricsAPIServicePath: {http.StatusOK, metricsAPIServiceWithStatus("True", "Passed", "all checks passed")},
+				podMetricsPath:        {http.StatusOK, podMetrics("coderd", "postgres")},
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `metrics API is available, and reports metrics for 2 pods in namespace "default"`, result.Summary)
+				assert.Equal(t, "available", "True", result.Details["available"])
+			},
+		},
+		{
+			Name: "apiservice forbidden",
+			Responses: map[string]testResponse{
+				metricsAPIServicePath: {http.StatusForbidden, nil},
+				podMetricsPath:        {http.StatusOK, podMetrics()},
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "available", "unknown", result.Details["available"])
+				assert.Equal(t, "pods", 0, result.Details["pods"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				resp, ok := test.Responses[req.URL.Path]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(resp.StatusCode)
+				if resp.Body != nil {
+					err := json.NewEncoder(w).Encode(resp.Body)
+					assert.Success(t, "failed to encode response", err)
+				}
+			}))
+			defer server.Close()
+
+			client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+			assert.Success(t, "failed to create client", err)
+
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckMetrics(context.Background()))
+		})
+	}
+}
+
+func Test_CheckMetrics_Fake(t *testing.T) {
+	t.Parallel()
+
+	result := NewKubernetesChecker(fake.NewSimpleClientset()).CheckMetrics(context.Background())
+	assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+}
+
+const (
+	metricsAPIServicePath = "/apis/apiregistration.k8s.io/v1/apiservices/v1beta1.metrics.k8s.io"
+	podMetricsPath        = "/apis/metrics.k8s.io/v1beta1/namespaces/default/pods"
+)
+
+type testResponse struct {
+	StatusCode int
+	Body       interface{}
+}
+
+func metricsAPIServiceWithStatus(status, reason, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"apiVersion": "apiregistration.k8s.io/v1",
+		"kind":       "APIService",
+		"metadata":   metav1.ObjectMeta{Name: metricsAPIService},
+		"spec": map[string]interface{}{
+			"service": map[string]interface{}{"namespace": "kube-system", "name": "metrics-server"},
+			"group":   "metrics.k8s.io",
+			"version": "v1beta1",
+		},
+		"status": map[string]interface{}{
+			"conditions": []map[string]interface{}{
+				{"type": "Available", "status": status, "reason": reason, "message": message},
+			},
+		},
+	}
+}
+
+func podMetrics(names ...string) map[string]interface{} {
+	items := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		items = append(items, map[string]interface{}{
+			"metadata": metav1.ObjectMeta{Namespace: "default", Name: name},
+		})
+	}
+	return map[string]interface{}{
+		"apiVersion": "metrics.k8s.io/v1beta1",
+		"kind":       "PodMetricsList",
+		"items":      items,
+	}
+}
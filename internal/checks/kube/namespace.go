@@ -0,0 +1,137 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/slog"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// CheckNamespace checks that the namespace Coder will be installed into
+// exists and is not being deleted. If it does not exist, the checks
+// scoped to it are not meaningful, so this checks whether the subject can
+// create it, as helm install --create-namespace would.
+func (k *KubernetesChecker) CheckNamespace(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-namespace"
+
+	ns, err := k.client.CoreV1().Namespaces().Get(ctx, k.namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return k.checkCreateNamespace(ctx, checkName)
+	}
+	if apierrors.IsForbidden(err) {
+		return k.probeNamespace(ctx, checkName, err)
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, fmt.Sprintf("failed to get namespace %q", k.namespace), err)
+	}
+
+	labels := ns.Labels
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	annotations := ns.Annotations
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"namespace":   k.namespace,
+			"phase":       string(ns.Status.Phase),
+			"labels":      labels,
+			"annotations": annotations,
+		},
+	}
+
+	if ns.Status.Phase == corev1.NamespaceTerminating || ns.DeletionTimestamp != nil {
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("namespace %q is being deleted", k.namespace)
+		result.Remediation = fmt.Sprintf("Wait for namespace %s to be deleted and create it again, or install Coder into another namespace", k.namespace)
+		return result
+	}
+
+	result.State = api.StatePassed
+	result.Summary = fmt.Sprintf("namespace %q exists", k.namespace)
+	return result
+}
+
+// probeNamespace checks that the namespace exists without permission to
+// get namespaces, which are cluster-scoped, by getting the default
+// ServiceAccount created in every namespace. Its labels, annotations and
+// phase cannot be reported.
+func (k *KubernetesChecker) probeNamespace(ctx context.Context, checkName string, forbidden error) *api.CheckResult {
+	_, err := k.client.CoreV1().ServiceAccounts(k.namespace).Get(ctx, "default", metav1.GetOptions{})
+	if err == nil {
+		result := api.PassResult(checkName, fmt.Sprintf("namespace %q exists", k.namespace))
+		result.Details["namespace"] = k.namespace
+		return result
+	}
+
+	// The default ServiceAccount may be missing because the namespace
+	// does not exist, or because it has not been created yet, so this is
+	// not conclusive.
+	k.log.Debug(ctx, "unable to get default service account", slog.F("namespace", k.namespace), slog.Error(err))
+	result := api.SkippedResult(checkName, fmt.Sprintf("unable to check that namespace %q exists without permission to get namespaces", k.namespace), forbidden)
+	result.Details["namespace"] = k.namespace
+	result.Remediation = fmt.Sprintf("Run the checks as a user who can get namespaces, or check that the namespace exists with: kubectl get namespace %s",
+		k.namespace)
+	return result
+}
+
+// checkCreateNamespace reports a missing namespace, and whether the subject
+// can create it.
+func (k *KubernetesChecker) checkCreateNamespace(ctx context.Context, checkName string) *api.CheckResult {
+	who := "the current user"
+	if k.subject != nil {
+		who = k.subjectUser
+	}
+
+	allowed, err := k.reviewAccess(ctx, k.client.AuthorizationV1(), &authorizationv1.ResourceAttributes{
+		Verb:     "create",
+		Resource: "namespaces",
+	})
+	if apierrors.IsForbidden(err) {
+		// Reviewing the access of another subject requires permission to
+		// create SubjectAccessReviews.
+		result := api.SkippedResult(checkName, fmt.Sprintf("namespace %q does not exist, and permission to create it could not be checked", k.namespace), err)
+		result.Details["namespace"] = k.namespace
+		result.Remediation = fmt.Sprintf("Create the namespace with: kubectl create namespace %s\n"+
+			"or pass --create-namespace to helm install, and run the checks again", k.namespace)
+		return result
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to check permission to create namespaces", err)
+	}
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"namespace":  k.namespace,
+			"can-create": allowed,
+		},
+	}
+
+	if allowed {
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("namespace %q does not exist, but %s can create it, so checks scoped to the namespace may be inaccurate",
+			k.namespace, who)
+		result.Remediation = fmt.Sprintf("Create the namespace with: kubectl create namespace %s\n"+
+			"or pass --create-namespace to helm install, and run the checks again", k.namespace)
+		return result
+	}
+
+	result.State = api.StateFailed
+	result.Summary = fmt.Sprintf("namespace %q does not exist, and %s cannot create it", k.namespace, who)
+	result.Remediation = fmt.Sprintf("Ask a cluster administrator to create the namespace with: kubectl create namespace %s\n"+
+		"or grant permission to create namespaces with:\n%s",
+		k.namespace, rbacRemediation(k.namespace, NewResourceRequirement("", "v1", "namespaces"), ResourceVerbs{"create"}, k.subjectFlag()))
+	return result
+}
@@ -0,0 +1,159 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckNamespace(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name      string
+		Objects   []runtime.Object
+		CanCreate bool
+		F         func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name: "exists",
+			Objects: []runtime.Object{&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: map[string]string{"team": "platform"}},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+			}},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `namespace "default" exists`, result.Summary)
+				assert.Equal(t, "labels", map[string]string{"team": "platform"}, result.Details["labels"])
+				assert.Equal(t, "annotations", map[string]string{}, result.Details["annotations"])
+				assert.Equal(t, "phase", "Active", result.Details["phase"])
+			},
+		},
+		{
+			Name: "terminating",
+			Objects: []runtime.Object{&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default"},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceTerminating},
+			}},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", `namespace "default" is being deleted`, result.Summary)
+			},
+		},
+		{
+			Name:      "missing and creatable",
+			CanCreate: true,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", `namespace "default" does not exist, but the current user can create it, so checks scoped to the namespace may be inaccurate`, result.Summary)
+				assert.Equal(t, "can create", true, result.Details["can-create"])
+			},
+		},
+		{
+			Name: "missing",
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", `namespace "default" does not exist, and the current user cannot create it`, result.Summary)
+				assert.Equal(t, "remediation", "Ask a cluster administrator to create the namespace with: kubectl create namespace default\n"+
+					"or grant permission to create namespaces with:\n"+
+					"kubectl create clusterrole coder-namespaces --verb=create --resource=namespaces\n"+
+					"kubectl create clusterrolebinding coder-namespaces --clusterrole=coder-namespaces --user=<user>", result.Remediation)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			client.Fake.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ssar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				assert.Equal(t, "verb", "create", ssar.Spec.ResourceAttributes.Verb)
+				assert.Equal(t, "resource", "namespaces", ssar.Spec.ResourceAttributes.Resource)
+				if test.CanCreate {
+					return true, &selfSubjectAccessReviewAllowed, nil
+				}
+				return true, &selfSubjectAccessReviewDenied, nil
+			})
+
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckNamespace(context.Background()))
+		})
+	}
+}
+
+func Test_CheckNamespace_Error(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.Fake.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, xerrors.New("ouch")
+	})
+
+	result := NewKubernetesChecker(client).CheckNamespace(context.Background())
+	assert.Equal(t, "should fail", api.StateFailed, result.State)
+	assert.Equal(t, "summary", `failed to get namespace "default"`, result.Summary)
+}
+
+func Test_CheckNamespace_Forbidden(t *testing.T) {
+	t.Parallel()
+
+	forbidNamespaces := func(client *fake.Clientset) {
+		client.Fake.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "namespaces"}, "default", xerrors.New("RBAC denied"))
+		})
+	}
+
+	t.Run("default service account exists", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset(&corev1.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "default"},
+		})
+		forbidNamespaces(client)
+
+		result := NewKubernetesChecker(client).CheckNamespace(context.Background())
+		assert.Equal(t, "should pass", api.StatePassed, result.State)
+		assert.Equal(t, "summary", `namespace "default" exists`, result.Summary)
+	})
+
+	t.Run("default service account missing", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		forbidNamespaces(client)
+
+		result := NewKubernetesChecker(client).CheckNamespace(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", `unable to check that namespace "default" exists without permission to get namespaces`, result.Summary)
+		assert.Equal(t, "remediation", "Run the checks as a user who can get namespaces, or check that the namespace exists with: kubectl get namespace default", result.Remediation)
+	})
+
+	t.Run("access review", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "authorization.k8s.io", Resource: "subjectaccessreviews"}, "", xerrors.New("RBAC denied"))
+		})
+
+		result := NewKubernetesChecker(client, WithServiceAccount("coder", "coder")).CheckNamespace(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", `namespace "default" does not exist, and permission to create it could not be checked`, result.Summary)
+	})
+}
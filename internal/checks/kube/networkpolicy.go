@@ -0,0 +1,146 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// networkPolicyProviderDocsURL lists network plugins which enforce
+// NetworkPolicies.
+const networkPolicyProviderDocsURL = "https://kubernetes.io/docs/tasks/administer-cluster/network-policy-provider/"
+
+// networkPlugin describes how to recognize a network plugin from the
+// DaemonSets in kube-system, and whether it enforces NetworkPolicies.
+type networkPlugin struct {
+	Name string
+	// DaemonSets are prefixes of the names of DaemonSets run by the
+	// plugin.
+	DaemonSets []string
+	// Images are substrings of the container images used by the plugin.
+	Images []string
+	// EnforcesNetworkPolicy is true if the plugin enforces NetworkPolicies.
+	EnforcesNetworkPolicy bool
+}
+
+// knownNetworkPlugins lists the network plugins recognized by
+// CheckNetworkPolicy. Some plugins only provide NetworkPolicy enforcement,
+// and are used alongside one that does not, such as Calico with the AWS VPC
+// CNI.
+var knownNetworkPlugins = []networkPlugin{
+	{Name: "Antrea", DaemonSets: []string{"antrea-agent"}, Images: []string{"antrea/antrea"}, EnforcesNetworkPolicy: true},
+	{Name: "Azure Network Policy Manager", DaemonSets: []string{"azure-npm"}, Images: []string{"azure-npm"}, EnforcesNetworkPolicy: true},
+	{Name: "Calico", DaemonSets: []string{"calico-node"}, Images: []string{"calico/node"}, EnforcesNetworkPolicy: true},
+	{Name: "Canal", DaemonSets: []string{"canal"}, EnforcesNetworkPolicy: true},
+	{Name: "Cilium", DaemonSets: []string{"cilium", "anetd"}, Images: []string{"cilium/cilium"}, EnforcesNetworkPolicy: true},
+	{Name: "kube-router", DaemonSets: []string{"kube-router"}, Images: []string{"kube-router"}, EnforcesNetworkPolicy: true},
+	{Name: "Weave Net", DaemonSets: []string{"weave-net"}, Images: []string{"weaveworks/weave-kube"}, EnforcesNetworkPolicy: true},
+	{Name: "AWS VPC CNI", DaemonSets: []string{"aws-node"}, Images: []string{"amazon-k8s-cni"}},
+	{Name: "flannel", DaemonSets: []string{"kube-flannel"}, Images: []string{"flannel"}},
+	{Name: "kindnet", DaemonSets: []string{"kindnet"}, Images: []string{"kindnetd"}},
+}
+
+// identifyNetworkPlugin returns the plugin running the DaemonSet, or nil if
+// it is not recognized. DaemonSet names are matched before images, since
+// some plugins, such as Canal, bundle the images of others.
+func identifyNetworkPlugin(ds *appsv1.DaemonSet) *networkPlugin {
+	for i, plugin := range knownNetworkPlugins {
+		for _, prefix := range plugin.DaemonSets {
+			if strings.HasPrefix(ds.Name, prefix) {
+				return &knownNetworkPlugins[i]
+			}
+		}
+	}
+
+	for i, plugin := range knownNetworkPlugins {
+		for _, container := range ds.Spec.Template.Spec.Containers {
+			for _, image := range plugin.Images {
+				if strings.Contains(container.Image, image) {
+					return &knownNetworkPlugins[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// CheckNetworkPolicy identifies the cluster's network plugin from the
+// DaemonSets in kube-system, and warns if it is not known to enforce the
+// NetworkPolicies used to isolate Coder workspaces.
+func (k *KubernetesChecker) CheckNetworkPolicy(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-network-policy"
+
+	daemonSets, err := k.client.AppsV1().DaemonSets(metav1.NamespaceSystem).List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		// Reading kube-system is not needed to install Coder, so this is
+		// not a failure.
+		result := api.SkippedResult(checkName, "unable to identify the network plugin without permission to list daemonsets in kube-system", err)
+		result.Remediation = "Run the checks as a user who can list DaemonSets in kube-system, such as a cluster administrator, " +
+			"or check that the cluster's network plugin enforces NetworkPolicies"
+		result.DocsURL = networkPolicyProviderDocsURL
+		return result
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list daemonsets in kube-system", err)
+	}
+
+	found := make(map[string]bool)
+	names := make([]string, 0, len(daemonSets.Items))
+	for i := range daemonSets.Items {
+		ds := &daemonSets.Items[i]
+		names = append(names, ds.Name)
+		if plugin := identifyNetworkPlugin(ds); plugin != nil {
+			found[plugin.Name] = true
+		}
+	}
+	sort.Strings(names)
+
+	// Report plugins in a stable order.
+	detected := make([]string, 0, len(found))
+	var enforcing, notEnforcing []string
+	for _, plugin := range knownNetworkPlugins {
+		if !found[plugin.Name] {
+			continue
+		}
+		detected = append(detected, plugin.Name)
+		if plugin.EnforcesNetworkPolicy {
+			enforcing = append(enforcing, plugin.Name)
+		} else {
+			notEnforcing = append(notEnforcing, plugin.Name)
+		}
+	}
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"daemonsets":      names,
+			"network-plugins": detected,
+		},
+	}
+
+	switch {
+	case len(enforcing) > 0:
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("network plugin %s enforces NetworkPolicies", strings.Join(enforcing, ", "))
+	case len(notEnforcing) > 0:
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("network plugin %s does not enforce NetworkPolicies, so workspaces will not be isolated from each other",
+			strings.Join(notEnforcing, ", "))
+		result.Remediation = "Install a network plugin that enforces NetworkPolicies, such as Calico, alongside or instead of the current one"
+		result.DocsURL = networkPolicyProviderDocsURL
+	default:
+		result.State = api.StateWarning
+		result.Summary = "could not identify the network plugin, so NetworkPolicy enforcement is unknown"
+		result.Remediation = "Check that the cluster's network plugin enforces NetworkPolicies, which Coder uses to isolate workspaces"
+		result.DocsURL = networkPolicyProviderDocsURL
+	}
+
+	return result
+}
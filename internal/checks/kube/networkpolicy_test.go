@@ -0,0 +1,155 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckNetworkPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Objects []runtime.Object
+		F       func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name: "calico",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "calico-node", "docker.io/calico/node:v3.20.0"),
+				daemonSet(metav1.NamespaceSystem, "kube-proxy", "k8s.gcr.io/kube-proxy:v1.21.0"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", "network plugin Calico enforces NetworkPolicies", result.Summary)
+				assert.Equal(t, "daemonsets", []string{"calico-node", "kube-proxy"}, result.Details["daemonsets"])
+			},
+		},
+		{
+			Name: "canal bundles flannel",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "canal", "calico/node:v3.20.0", "quay.io/coreos/flannel:v0.14.0"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "plugins", []string{"Canal"}, result.Details["network-plugins"])
+			},
+		},
+		{
+			Name: "aws vpc cni with calico",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "aws-node", "602401143452.dkr.ecr.us-west-2.amazonaws.com/amazon-k8s-cni:v1.9.0"),
+				daemonSet(metav1.NamespaceSystem, "calico-node", "quay.io/calico/node:v3.19.1"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "plugins", []string{"Calico", "AWS VPC CNI"}, result.Details["network-plugins"])
+			},
+		},
+		{
+			Name: "flannel by image",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "overlay", "quay.io/coreos/flannel:v0.14.0"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", "network plugin flannel does not enforce NetworkPolicies, so workspaces will not be isolated from each other", result.Summary)
+				assert.Equal(t, "docs", networkPolicyProviderDocsURL, result.DocsURL)
+			},
+		},
+		{
+			Name: "kindnet",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "kindnet", "docker.io/kindest/kindnetd:v20210326-1e038dc5"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "plugins", []string{"kindnet"}, result.Details["network-plugins"])
+			},
+		},
+		{
+			Name: "unknown",
+			Objects: []runtime.Object{
+				daemonSet(metav1.NamespaceSystem, "kube-proxy", "k8s.gcr.io/kube-proxy:v1.21.0"),
+				daemonSet("other", "calico-node", "calico/node:v3.20.0"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", "could not identify the network plugin, so NetworkPolicy enforcement is unknown", result.Summary)
+				assert.Equal(t, "plugins", []string{}, result.Details["network-plugins"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckNetworkPolicy(context.Background()))
+		})
+	}
+}
+
+func Test_CheckNetworkPolicy_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("list daemonsets", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "daemonsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckNetworkPolicy(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "ouch")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "daemonsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "apps", Resource: "daemonsets"}, "", xerrors.New("RBAC denied"))
+		})
+
+		result := NewKubernetesChecker(client).CheckNetworkPolicy(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", "unable to identify the network plugin without permission to list daemonsets in kube-system", result.Summary)
+		assert.True(t, "remediation", result.Remediation != "")
+	})
+}
+
+func daemonSet(namespace, name string, images ...string) *appsv1.DaemonSet {
+	containers := make([]corev1.Container, 0, len(images))
+	for _, image := range images {
+		containers = append(containers, corev1.Container{Name: name, Image: image})
+	}
+
+	return &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: appsv1.DaemonSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{Containers: containers},
+			},
+		},
+	}
+}
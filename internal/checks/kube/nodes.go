@@ -0,0 +1,246 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/slog"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+// archLabel is the node label giving the node's CPU architecture.
+const archLabel = "kubernetes.io/arch"
+
+// VersionedNodeRequirements describes the smallest node able to run the
+// Coder control plane, for the versions of Coder matching the constraints.
+type VersionedNodeRequirements struct {
+	VersionConstraints *semver.Constraints
+	CPU                resource.Quantity
+	Memory             resource.Quantity
+	EphemeralStorage   resource.Quantity
+	Architectures      []string
+	// description is a short summary of the requirements.
+	description string
+}
+
+// nodeRequirementsFromManifest returns the node requirements for each
+// version of Coder listed in the manifest, ordered by version descending.
+func nodeRequirementsFromManifest(m *requirements.Manifest) []VersionedNodeRequirements {
+	all := make([]VersionedNodeRequirements, 0, len(m.Nodes))
+	for _, nodes := range m.Nodes {
+		all = append(all, VersionedNodeRequirements{
+			VersionConstraints: nodes.Constraint.Constraints,
+			CPU:                nodes.CPU,
+			Memory:             nodes.Memory,
+			EphemeralStorage:   nodes.EphemeralStorage,
+			Architectures:      nodes.Architectures,
+			description:        nodes.String(),
+		})
+	}
+	return all
+}
+
+func findClosestNodeRequirements(all []VersionedNodeRequirements, v *semver.Version) *VersionedNodeRequirements {
+	for _, reqs := range all {
+		if reqs.VersionConstraints.Check(v) {
+			return &reqs
+		}
+	}
+	return nil
+}
+
+// nodeSummary describes a node, and whether it can run the Coder control
+// plane.
+type nodeSummary struct {
+	Name             string   `json:"name"`
+	CPU              string   `json:"cpu"`
+	Memory           string   `json:"memory"`
+	EphemeralStorage string   `json:"ephemeralStorage"`
+	Architecture     string   `json:"architecture"`
+	OSImage          string   `json:"osImage"`
+	KernelVersion    string   `json:"kernelVersion"`
+	ContainerRuntime string   `json:"containerRuntime"`
+	Schedulable      bool     `json:"schedulable"`
+	Problems         []string `json:"problems,omitempty"`
+}
+
+// CheckNodes checks that at least one schedulable node has the capacity
+// and architecture required to run the Coder control plane. It reports
+// the capacity and system information of each node.
+func (k *KubernetesChecker) CheckNodes(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-nodes"
+
+	if k.nodeReqs == nil {
+		return api.SkippedResult(checkName, fmt.Sprintf("no node requirements for Coder %s", k.coderVersion), nil)
+	}
+
+	nodes, err := k.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		// Nodes are cluster-scoped, and listing them is not needed to
+		// install Coder, so this is not a failure.
+		result := api.SkippedResult(checkName, "unable to check node capacity without permission to list nodes", err)
+		result.Remediation = fmt.Sprintf("Run the checks as a user who can list nodes, such as a cluster administrator, "+
+			"or check that a node has at least %s", k.nodeReqs.description)
+		return result
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list nodes", err)
+	}
+
+	if len(nodes.Items) == 0 {
+		return &api.CheckResult{
+			Name:        checkName,
+			State:       api.StateFailed,
+			Summary:     "cluster has no nodes",
+			Remediation: fmt.Sprintf("Add nodes with at least %s", k.nodeReqs.description),
+		}
+	}
+
+	summaries := make([]nodeSummary, 0, len(nodes.Items))
+	suitable := 0
+	architectures := make(map[string]bool)
+	for i := range nodes.Items {
+		summary := k.summarizeNode(&nodes.Items[i])
+		k.log.Debug(ctx, "found node",
+			slog.F("name", summary.Name),
+			slog.F("cpu", summary.CPU),
+			slog.F("memory", summary.Memory),
+			slog.F("architecture", summary.Architecture),
+			slog.F("problems", summary.Problems))
+
+		if summary.Schedulable {
+			architectures[summary.Architecture] = true
+			if len(summary.Problems) == 0 {
+				suitable++
+			}
+		}
+		summaries = append(summaries, summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Name < summaries[j].Name
+	})
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"nodes":    summaries,
+			"required": k.nodeReqs.description,
+		},
+	}
+
+	if suitable > 0 {
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("%d of %d nodes meet the minimum requirements (%s)",
+			suitable, len(summaries), k.nodeReqs.description)
+		return result
+	}
+
+	result.State = api.StateFailed
+	result.Summary = fmt.Sprintf("no schedulable node meets the minimum requirements (%s)", k.nodeReqs.description)
+	result.Remediation = fmt.Sprintf("Add a node pool with at least %s per node", k.nodeReqs.description)
+
+	supported := false
+	for _, arch := range k.nodeReqs.Architectures {
+		if architectures[arch] {
+			supported = true
+		}
+	}
+	if !supported && len(architectures) > 0 {
+		found := make([]string, 0, len(architectures))
+		for arch := range architectures {
+			found = append(found, arch)
+		}
+		sort.Strings(found)
+
+		result.Summary = fmt.Sprintf("no schedulable node has a supported architecture (%s), found: %s",
+			strings.Join(k.nodeReqs.Architectures, ", "), strings.Join(found, ", "))
+	}
+	return result
+}
+
+// summarizeNode returns the capacity and system information of a node,
+// and the reasons it cannot run the Coder control plane, if any.
+func (k *KubernetesChecker) summarizeNode(node *corev1.Node) nodeSummary {
+	allocatable := node.Status.Allocatable
+	cpu := allocatable[corev1.ResourceCPU]
+	memory := allocatable[corev1.ResourceMemory]
+	storage := allocatable[corev1.ResourceEphemeralStorage]
+
+	arch := node.Labels[archLabel]
+	if arch == "" {
+		arch = node.Status.NodeInfo.Architecture
+	}
+
+	summary := nodeSummary{
+		Name:             node.Name,
+		CPU:              cpu.String(),
+		Memory:           memory.String(),
+		EphemeralStorage: storage.String(),
+		Architecture:     arch,
+		OSImage:          node.Status.NodeInfo.OSImage,
+		KernelVersion:    node.Status.NodeInfo.KernelVersion,
+		ContainerRuntime: node.Status.NodeInfo.ContainerRuntimeVersion,
+	}
+
+	if reason := unschedulableReason(node); reason != "" {
+		summary.Problems = append(summary.Problems, reason)
+	} else {
+		summary.Schedulable = true
+	}
+
+	reqs := k.nodeReqs
+	if cpu.Cmp(reqs.CPU) < 0 {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("allocatable CPU %s is less than %s", cpu.String(), reqs.CPU.String()))
+	}
+	if memory.Cmp(reqs.Memory) < 0 {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("allocatable memory %s is less than %s", memory.String(), reqs.Memory.String()))
+	}
+	if storage.Cmp(reqs.EphemeralStorage) < 0 {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("allocatable ephemeral storage %s is less than %s", storage.String(), reqs.EphemeralStorage.String()))
+	}
+
+	supported := false
+	for _, a := range reqs.Architectures {
+		if a == arch {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		summary.Problems = append(summary.Problems, fmt.Sprintf("architecture %q is not one of: %s", arch, strings.Join(reqs.Architectures, ", ")))
+	}
+
+	return summary
+}
+
+// unschedulableReason returns the reason pods without tolerations cannot
+// be scheduled onto the node, or an empty string if they can.
+func unschedulableReason(node *corev1.Node) string {
+	if node.Spec.Unschedulable {
+		return "node is cordoned"
+	}
+
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == corev1.NodeReady && cond.Status != corev1.ConditionTrue {
+			return "node is not ready"
+		}
+	}
+
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+			return fmt.Sprintf("node has taint %s:%s", taint.Key, taint.Effect)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,207 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+func Test_CheckNodes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Objects []runtime.Object
+		F       func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name:    "no nodes",
+			Objects: nil,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "cluster has no nodes", result.Summary)
+			},
+		},
+		{
+			Name: "one suitable node",
+			Objects: []runtime.Object{
+				capacityNode("small", "1", "2Gi", "amd64"),
+				capacityNode("large", "4", "16Gi", "amd64"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", "1 of 2 nodes meet the minimum requirements (2 CPU, 4Gi memory, 10Gi ephemeral storage, amd64)", result.Summary)
+
+				nodes := result.Details["nodes"].([]nodeSummary)
+				assert.Equal(t, "nodes", 2, len(nodes))
+				assert.Equal(t, "sorted by name", "large", nodes[0].Name)
+				assert.Equal(t, "large node info", nodeSummary{
+					Name:             "large",
+					CPU:              "4",
+					Memory:           "16Gi",
+					EphemeralStorage: "100Gi",
+					Architecture:     "amd64",
+					OSImage:          "Container-Optimized OS from Google",
+					KernelVersion:    "5.4.120+",
+					ContainerRuntime: "containerd://1.4.4",
+					Schedulable:      true,
+				}, nodes[0])
+				assert.Equal(t, "small node problems", []string{
+					"allocatable CPU 1 is less than 2",
+					"allocatable memory 2Gi is less than 4Gi",
+				}, nodes[1].Problems)
+			},
+		},
+		{
+			Name: "arm only",
+			Objects: []runtime.Object{
+				capacityNode("arm-a", "4", "16Gi", "arm64"),
+				capacityNode("arm-b", "4", "16Gi", "arm64"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "no schedulable node has a supported architecture (amd64), found: arm64", result.Summary)
+				assert.Equal(t, "remediation", "Add a node pool with at least 2 CPU, 4Gi memory, 10Gi ephemeral storage, amd64 per node", result.Remediation)
+			},
+		},
+		{
+			Name: "architecture from node info",
+			Objects: []runtime.Object{
+				func() *corev1.Node {
+					n := capacityNode("unlabeled", "4", "16Gi", "")
+					n.Status.NodeInfo.Architecture = "amd64"
+					return n
+				}(),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+			},
+		},
+		{
+			Name: "large nodes unschedulable",
+			Objects: []runtime.Object{
+				capacityNode("small", "1", "4Gi", "amd64"),
+				func() *corev1.Node {
+					n := capacityNode("cordoned", "8", "32Gi", "amd64")
+					n.Spec.Unschedulable = true
+					return n
+				}(),
+				func() *corev1.Node {
+					n := capacityNode("tainted", "8", "32Gi", "amd64")
+					n.Spec.Taints = []corev1.Taint{
+						{Key: "example.com/prefer", Effect: corev1.TaintEffectPreferNoSchedule},
+						{Key: "node-role.kubernetes.io/master", Effect: corev1.TaintEffectNoSchedule},
+					}
+					return n
+				}(),
+				func() *corev1.Node {
+					n := capacityNode("not-ready", "8", "32Gi", "amd64")
+					n.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionUnknown}}
+					return n
+				}(),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "no schedulable node meets the minimum requirements (2 CPU, 4Gi memory, 10Gi ephemeral storage, amd64)", result.Summary)
+
+				nodes := result.Details["nodes"].([]nodeSummary)
+				assert.Equal(t, "cordoned", []string{"node is cordoned"}, nodes[0].Problems)
+				assert.Equal(t, "not ready", []string{"node is not ready"}, nodes[1].Problems)
+				assert.Equal(t, "too small", []string{"allocatable CPU 1 is less than 2"}, nodes[2].Problems)
+				assert.Equal(t, "tainted", []string{"node has taint node-role.kubernetes.io/master:NoSchedule"}, nodes[3].Problems)
+				assert.False(t, "tainted node is not schedulable", nodes[3].Schedulable)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckNodes(context.Background()))
+		})
+	}
+}
+
+func Test_CheckNodes_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("list nodes", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckNodes(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "ouch")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "", xerrors.New("RBAC denied"))
+		})
+
+		result := NewKubernetesChecker(client).CheckNodes(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", "unable to check node capacity without permission to list nodes", result.Summary)
+		assert.Equal(t, "remediation", "Run the checks as a user who can list nodes, such as a cluster administrator, "+
+			"or check that a node has at least 2 CPU, 4Gi memory, 10Gi ephemeral storage, amd64", result.Remediation)
+	})
+
+	t.Run("no node requirements", func(t *testing.T) {
+		t.Parallel()
+
+		m := requirements.Default()
+		m.Nodes = nil
+
+		result := NewKubernetesChecker(fake.NewSimpleClientset(), WithRequirements(m)).CheckNodes(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", "no node requirements for Coder 100.0.0", result.Summary)
+	})
+}
+
+func capacityNode(name, cpu, memory, arch string) *corev1.Node {
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Status: corev1.NodeStatus{
+			Allocatable: corev1.ResourceList{
+				corev1.ResourceCPU:              resource.MustParse(cpu),
+				corev1.ResourceMemory:           resource.MustParse(memory),
+				corev1.ResourceEphemeralStorage: resource.MustParse("100Gi"),
+			},
+			Conditions: []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue}},
+			NodeInfo: corev1.NodeSystemInfo{
+				OSImage:                 "Container-Optimized OS from Google",
+				KernelVersion:           "5.4.120+",
+				ContainerRuntimeVersion: "containerd://1.4.4",
+			},
+		},
+	}
+	if arch != "" {
+		n.Labels = map[string]string{archLabel: arch}
+	}
+	return n
+}
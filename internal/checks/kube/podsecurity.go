@@ -0,0 +1,196 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// podSecurityDocsURL documents the Pod Security Standards.
+const podSecurityDocsURL = "https://kubernetes.io/docs/concepts/security/pod-security-standards/"
+
+// Pod Security Standard levels, from least to most restrictive.
+const (
+	podSecurityPrivileged = "privileged"
+	podSecurityBaseline   = "baseline"
+	podSecurityRestricted = "restricted"
+)
+
+// podSecurityModes are the Pod Security Admission modes, each configured
+// by a namespace label.
+var podSecurityModes = []string{"enforce", "warn", "audit"}
+
+// podSecurityLabel returns the namespace label configuring the given Pod
+// Security Admission mode, or its version if version is true.
+func podSecurityLabel(mode string, version bool) string {
+	if version {
+		return fmt.Sprintf("pod-security.kubernetes.io/%s-version", mode)
+	}
+	return "pod-security.kubernetes.io/" + mode
+}
+
+// CheckPodSecurity checks that the Pod Security Admission labels on the
+// namespace allow Coder's pods. Workspaces using container-based virtual
+// machines or Docker need capabilities beyond the baseline standard, and
+// Coder's pods do not meet the restricted standard.
+func (k *KubernetesChecker) CheckPodSecurity(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-pod-security"
+
+	ns, err := k.client.CoreV1().Namespaces().Get(ctx, k.namespace, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		// CheckNamespace reports whether the namespace can be created.
+		return api.SkippedResult(checkName, fmt.Sprintf("namespace %q does not exist yet", k.namespace), nil)
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, fmt.Sprintf("failed to get namespace %q", k.namespace), err)
+	}
+
+	levels := make(map[string]string, len(podSecurityModes))
+	details := map[string]interface{}{
+		"namespace": k.namespace,
+	}
+	for _, mode := range podSecurityModes {
+		level := ns.Labels[podSecurityLabel(mode, false)]
+		if level == "" {
+			level = podSecurityPrivileged
+		}
+		levels[mode] = level
+		details[mode] = level
+		if version := ns.Labels[podSecurityLabel(mode, true)]; version != "" {
+			details[mode+"-version"] = version
+		}
+	}
+
+	result := &api.CheckResult{
+		Name:    checkName,
+		Details: details,
+	}
+
+	enforce := levels["enforce"]
+	switch enforce {
+	case podSecurityRestricted:
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("namespace %q enforces the %s Pod Security Standard, which rejects Coder's pods", k.namespace, enforce)
+	case podSecurityBaseline:
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("namespace %q enforces the %s Pod Security Standard, which rejects workspaces "+
+			"using container-based virtual machines or Docker", k.namespace, enforce)
+	case podSecurityPrivileged:
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("namespace %q allows privileged pods", k.namespace)
+	default:
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("namespace %q enforces unknown Pod Security Standard %q", k.namespace, enforce)
+	}
+
+	if result.State != api.StatePassed {
+		result.Remediation = fmt.Sprintf("Allow Coder's pods with: kubectl label --overwrite namespace %s %s=%s",
+			k.namespace, podSecurityLabel("enforce", false), podSecurityPrivileged)
+		result.DocsURL = podSecurityDocsURL
+		return result
+	}
+
+	// Stricter warn and audit levels do not reject pods, but are noisy.
+	var stricter []string
+	for _, mode := range podSecurityModes[1:] {
+		if levels[mode] != podSecurityPrivileged {
+			stricter = append(stricter, fmt.Sprintf("%s=%s", mode, levels[mode]))
+		}
+	}
+	if len(stricter) > 0 {
+		result.Summary += fmt.Sprintf(", but reports violations of stricter levels (%s)", strings.Join(stricter, ", "))
+	}
+	return result
+}
+
+// CheckPodSecurityPolicy checks whether PodSecurityPolicies exist, and if
+// so, that the subject whose permissions are checked can use at least one.
+// PodSecurityPolicy was removed in Kubernetes 1.25, so this check is
+// skipped on clusters that no longer serve it.
+func (k *KubernetesChecker) CheckPodSecurityPolicy(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-pod-security-policy"
+
+	policies, err := k.client.PolicyV1beta1().PodSecurityPolicies().List(ctx, metav1.ListOptions{})
+	if apierrors.IsNotFound(err) {
+		return api.SkippedResult(checkName, "cluster does not serve PodSecurityPolicies", nil)
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list pod security policies", err)
+	}
+
+	if len(policies.Items) == 0 {
+		return api.PassResult(checkName, "cluster has no PodSecurityPolicies")
+	}
+
+	who := "the current user"
+	if k.subject != nil {
+		who = k.subjectUser
+	}
+
+	authClient := k.client.AuthorizationV1()
+	usable := make([]string, 0)
+	privileged := make([]string, 0)
+	names := make([]string, 0, len(policies.Items))
+	for _, psp := range policies.Items {
+		names = append(names, psp.Name)
+
+		allowed, err := k.reviewAccess(ctx, authClient, &authorizationv1.ResourceAttributes{
+			Namespace: k.namespace,
+			Verb:      "use",
+			Group:     "policy",
+			Resource:  "podsecuritypolicies",
+			Name:      psp.Name,
+		})
+		if err != nil {
+			return api.ErrorResult(checkName, fmt.Sprintf("failed to check use of pod security policy %q", psp.Name), err)
+		}
+		if !allowed {
+			continue
+		}
+
+		usable = append(usable, psp.Name)
+		if psp.Spec.Privileged {
+			privileged = append(privileged, psp.Name)
+		}
+	}
+	sort.Strings(names)
+	sort.Strings(usable)
+	sort.Strings(privileged)
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"pod-security-policies": names,
+			"usable":                usable,
+			"privileged":            privileged,
+		},
+	}
+
+	switch {
+	case len(usable) == 0:
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("%s cannot use any of %d PodSecurityPolicies, so pods will be rejected if PodSecurityPolicy admission is enabled",
+			who, len(names))
+		result.Remediation = fmt.Sprintf("Grant use of a PodSecurityPolicy with:\n"+
+			"kubectl create role coder-psp --namespace=%s --verb=use --resource=podsecuritypolicies.policy --resource-name=<policy>\n"+
+			"kubectl create rolebinding coder-psp --namespace=%s --role=coder-psp %s",
+			k.namespace, k.namespace, k.subjectFlag())
+		result.DocsURL = "https://kubernetes.io/docs/concepts/security/pod-security-policy/#authorizing-policies"
+	case len(privileged) == 0:
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("%s can use PodSecurityPolicies %s, but none allows the privileged pods used by "+
+			"container-based virtual machines", who, strings.Join(usable, ", "))
+	default:
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("%s can use PodSecurityPolicies %s", who, strings.Join(usable, ", "))
+	}
+
+	return result
+}
@@ -0,0 +1,225 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckPodSecurity(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name   string
+		Labels map[string]string
+		F      func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name:   "no labels",
+			Labels: nil,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `namespace "default" allows privileged pods`, result.Summary)
+				assert.Equal(t, "enforce", "privileged", result.Details["enforce"])
+			},
+		},
+		{
+			Name: "stricter warn and audit",
+			Labels: map[string]string{
+				"pod-security.kubernetes.io/enforce":       "privileged",
+				"pod-security.kubernetes.io/warn":          "baseline",
+				"pod-security.kubernetes.io/audit":         "restricted",
+				"pod-security.kubernetes.io/audit-version": "v1.22",
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `namespace "default" allows privileged pods, but reports violations of stricter levels (warn=baseline, audit=restricted)`, result.Summary)
+				assert.Equal(t, "audit version", "v1.22", result.Details["audit-version"])
+			},
+		},
+		{
+			Name:   "baseline",
+			Labels: map[string]string{"pod-security.kubernetes.io/enforce": "baseline"},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "remediation", "Allow Coder's pods with: kubectl label --overwrite namespace default pod-security.kubernetes.io/enforce=privileged", result.Remediation)
+			},
+		},
+		{
+			Name:   "restricted",
+			Labels: map[string]string{"pod-security.kubernetes.io/enforce": "restricted"},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", `namespace "default" enforces the restricted Pod Security Standard, which rejects Coder's pods`, result.Summary)
+				assert.Equal(t, "docs", podSecurityDocsURL, result.DocsURL)
+			},
+		},
+		{
+			Name:   "unknown level",
+			Labels: map[string]string{"pod-security.kubernetes.io/enforce": "strict"},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(&corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: "default", Labels: test.Labels},
+			})
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckPodSecurity(context.Background()))
+		})
+	}
+}
+
+func Test_CheckPodSecurity_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing namespace", func(t *testing.T) {
+		t.Parallel()
+
+		result := NewKubernetesChecker(fake.NewSimpleClientset(), WithNamespace("missing")).CheckPodSecurity(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", `namespace "missing" does not exist yet`, result.Summary)
+	})
+
+	t.Run("get namespace", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("get", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckPodSecurity(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.Equal(t, "summary", `failed to get namespace "default"`, result.Summary)
+	})
+}
+
+func Test_CheckPodSecurityPolicy(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Objects []runtime.Object
+		// Usable lists the policies the user may use.
+		Usable []string
+		F      func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name: "no policies",
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", "cluster has no PodSecurityPolicies", result.Summary)
+			},
+		},
+		{
+			Name:    "privileged usable",
+			Objects: []runtime.Object{podSecurityPolicy("restricted", false), podSecurityPolicy("privileged", true)},
+			Usable:  []string{"restricted", "privileged"},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", "the current user can use PodSecurityPolicies privileged, restricted", result.Summary)
+				assert.Equal(t, "privileged", []string{"privileged"}, result.Details["privileged"])
+			},
+		},
+		{
+			Name:    "only unprivileged usable",
+			Objects: []runtime.Object{podSecurityPolicy("restricted", false), podSecurityPolicy("privileged", true)},
+			Usable:  []string{"restricted"},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "usable", []string{"restricted"}, result.Details["usable"])
+			},
+		},
+		{
+			Name:    "none usable",
+			Objects: []runtime.Object{podSecurityPolicy("restricted", false)},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "the current user cannot use any of 1 PodSecurityPolicies, so pods will be rejected if PodSecurityPolicy admission is enabled", result.Summary)
+				assert.Equal(t, "usable", []string{}, result.Details["usable"])
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			client.Fake.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				ssar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+				attrs := ssar.Spec.ResourceAttributes
+				assert.Equal(t, "verb", "use", attrs.Verb)
+				assert.Equal(t, "namespace", "default", attrs.Namespace)
+				for _, name := range test.Usable {
+					if attrs.Name == name {
+						return true, &selfSubjectAccessReviewAllowed, nil
+					}
+				}
+				return true, &selfSubjectAccessReviewDenied, nil
+			})
+
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckPodSecurityPolicy(context.Background()))
+		})
+	}
+}
+
+func Test_CheckPodSecurityPolicy_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not served", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "podsecuritypolicies", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewNotFound(schema.GroupResource{Group: "policy", Resource: "podsecuritypolicies"}, "")
+		})
+
+		result := NewKubernetesChecker(client).CheckPodSecurityPolicy(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+	})
+
+	t.Run("access review", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset(podSecurityPolicy("restricted", false))
+		client.Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client, WithServiceAccount("coder", "coder")).CheckPodSecurityPolicy(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "failed to create SubjectAccessReview request")
+	})
+}
+
+func podSecurityPolicy(name string, privileged bool) *policyv1beta1.PodSecurityPolicy {
+	return &policyv1beta1.PodSecurityPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       policyv1beta1.PodSecurityPolicySpec{Privileged: privileged},
+	}
+}
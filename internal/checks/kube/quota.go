@@ -0,0 +1,230 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// DefaultWorkspaces is the default number of workspaces the namespace's
+// quota is checked against.
+const DefaultWorkspaces = 10
+
+// resourceQuotaDocsURL documents ResourceQuotas.
+const resourceQuotaDocsURL = "https://kubernetes.io/docs/concepts/policy/resource-quotas/"
+
+// controlPlaneFootprint is the quota consumed by a default installation
+// of the Coder Helm chart: the coderd deployment, and the built-in
+// PostgreSQL database with its volume. Footprints are keyed by the names
+// used in ResourceQuotas.
+var controlPlaneFootprint = corev1.ResourceList{
+	corev1.ResourcePods:                   resource.MustParse("2"),
+	corev1.ResourcePersistentVolumeClaims: resource.MustParse("1"),
+	corev1.ResourceRequestsCPU:            resource.MustParse("500m"),
+	corev1.ResourceRequestsMemory:         resource.MustParse("1536Mi"),
+	corev1.ResourceRequestsStorage:        resource.MustParse("10Gi"),
+}
+
+// workspaceFootprint is the quota consumed by a workspace with the
+// default resources and a home volume.
+var workspaceFootprint = corev1.ResourceList{
+	corev1.ResourcePods:                   resource.MustParse("1"),
+	corev1.ResourcePersistentVolumeClaims: resource.MustParse("1"),
+	corev1.ResourceRequestsCPU:            resource.MustParse("1"),
+	corev1.ResourceRequestsMemory:         resource.MustParse("2Gi"),
+	corev1.ResourceRequestsStorage:        resource.MustParse("10Gi"),
+}
+
+// quotaResourceName returns the name used for a quota resource in the
+// footprints, which use the requests.* and plain object count forms.
+func quotaResourceName(name corev1.ResourceName) corev1.ResourceName {
+	switch name {
+	case corev1.ResourceCPU:
+		return corev1.ResourceRequestsCPU
+	case corev1.ResourceMemory:
+		return corev1.ResourceRequestsMemory
+	case "count/pods":
+		return corev1.ResourcePods
+	case "count/persistentvolumeclaims":
+		return corev1.ResourcePersistentVolumeClaims
+	}
+	return name
+}
+
+// quotaUsage describes the use of a resource limited by a ResourceQuota.
+type quotaUsage struct {
+	Quota     string `json:"quota"`
+	Resource  string `json:"resource"`
+	Hard      string `json:"hard"`
+	Used      string `json:"used"`
+	Remaining string `json:"remaining"`
+	// Required is the amount needed by the control plane and workspaces.
+	Required string `json:"required"`
+}
+
+// CheckResourceQuota checks that the ResourceQuotas in the namespace leave
+// room for the Coder control plane and the configured number of
+// workspaces, and that its LimitRanges allow a workspace's resources. It
+// fails if the control plane alone would exceed a quota, and warns if the
+// workspaces would.
+func (k *KubernetesChecker) CheckResourceQuota(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-resource-quota"
+
+	quotas, err := k.client.CoreV1().ResourceQuotas(k.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return k.quotaForbiddenResult(checkName, "resourcequotas", err)
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list resource quotas", err)
+	}
+
+	limitRanges, err := k.client.CoreV1().LimitRanges(k.namespace).List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		return k.quotaForbiddenResult(checkName, "limitranges", err)
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list limit ranges", err)
+	}
+
+	if len(quotas.Items) == 0 && len(limitRanges.Items) == 0 {
+		return api.PassResult(checkName, fmt.Sprintf("namespace %q has no ResourceQuotas or LimitRanges", k.namespace))
+	}
+
+	var failures, warnings []string
+	usages := make([]quotaUsage, 0)
+	for _, quota := range quotas.Items {
+		// The status may not yet be populated for new quotas, so the hard
+		// limits are taken from the spec.
+		for name, hard := range quota.Spec.Hard {
+			resourceName := quotaResourceName(name)
+			controlPlane, ok := controlPlaneFootprint[resourceName]
+			if !ok {
+				continue
+			}
+			workspace := workspaceFootprint[resourceName]
+
+			used := quota.Status.Used[name]
+			remaining := hard.DeepCopy()
+			remaining.Sub(used)
+
+			required := controlPlane.DeepCopy()
+			for i := 0; i < k.workspaces; i++ {
+				required.Add(workspace)
+			}
+
+			usages = append(usages, quotaUsage{
+				Quota:     quota.Name,
+				Resource:  string(name),
+				Hard:      hard.String(),
+				Used:      used.String(),
+				Remaining: remaining.String(),
+				Required:  required.String(),
+			})
+
+			switch {
+			case remaining.Cmp(controlPlane) < 0:
+				failures = append(failures, fmt.Sprintf("quota %q has %s %s remaining, but the control plane requires %s",
+					quota.Name, remaining.String(), name, controlPlane.String()))
+			case remaining.Cmp(required) < 0:
+				fit := int64(0)
+				if workspace.MilliValue() > 0 {
+					fit = (remaining.MilliValue() - controlPlane.MilliValue()) / workspace.MilliValue()
+				}
+				warnings = append(warnings, fmt.Sprintf("quota %q has %s %s remaining, enough for the control plane and %d of %d workspaces",
+					quota.Name, remaining.String(), name, fit, k.workspaces))
+			}
+		}
+	}
+
+	warnings = append(warnings, limitRangeProblems(limitRanges.Items)...)
+
+	// Map iteration order is random, so sort for stable output.
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].Quota != usages[j].Quota {
+			return usages[i].Quota < usages[j].Quota
+		}
+		return usages[i].Resource < usages[j].Resource
+	})
+	sort.Strings(failures)
+	sort.Strings(warnings)
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"namespace":  k.namespace,
+			"workspaces": k.workspaces,
+			"quotas":     usages,
+		},
+	}
+
+	switch {
+	case len(failures) > 0:
+		result.State = api.StateFailed
+		result.Summary = strings.Join(failures, "; ")
+		if len(warnings) > 0 {
+			result.Details["warnings"] = warnings
+		}
+		result.Remediation = fmt.Sprintf("Ask a cluster administrator to raise the ResourceQuota in namespace %s, or install Coder into another namespace", k.namespace)
+		result.DocsURL = resourceQuotaDocsURL
+	case len(warnings) > 0:
+		result.State = api.StateWarning
+		result.Summary = strings.Join(warnings, "; ")
+		result.Remediation = fmt.Sprintf("Ask a cluster administrator to raise the ResourceQuota or LimitRange in namespace %s "+
+			"to fit the expected number of workspaces, which can be set with --workspaces", k.namespace)
+		result.DocsURL = resourceQuotaDocsURL
+	default:
+		result.State = api.StatePassed
+		result.Summary = fmt.Sprintf("namespace %q has quota for the control plane and %d workspaces", k.namespace, k.workspaces)
+	}
+
+	return result
+}
+
+// quotaForbiddenResult skips CheckResourceQuota when the given resource
+// cannot be listed, since listing it is not needed to install Coder.
+func (k *KubernetesChecker) quotaForbiddenResult(checkName, resource string, err error) *api.CheckResult {
+	result := api.SkippedResult(checkName,
+		fmt.Sprintf("unable to check quotas without permission to list %s in namespace %q", resource, k.namespace), err)
+	result.Details["namespace"] = k.namespace
+	result.Remediation = fmt.Sprintf("Run the checks as a user who can list %s in namespace %s, or check the quotas with: "+
+		"kubectl describe resourcequotas,limitranges --namespace=%s", resource, k.namespace, k.namespace)
+	result.DocsURL = resourceQuotaDocsURL
+	return result
+}
+
+// limitRangeProblems returns the ways in which the LimitRanges would
+// reject a workspace with the default resources.
+func limitRangeProblems(limitRanges []corev1.LimitRange) []string {
+	var problems []string
+	for _, lr := range limitRanges {
+		for _, item := range lr.Spec.Limits {
+			switch item.Type {
+			case corev1.LimitTypeContainer, corev1.LimitTypePod:
+				for _, name := range []corev1.ResourceName{corev1.ResourceCPU, corev1.ResourceMemory} {
+					limit, ok := item.Max[name]
+					want := workspaceFootprint[quotaResourceName(name)]
+					if ok && limit.Cmp(want) < 0 {
+						problems = append(problems, fmt.Sprintf("LimitRange %q limits %s %s to %s, but a workspace requests %s",
+							lr.Name, strings.ToLower(string(item.Type)), name, limit.String(), want.String()))
+					}
+				}
+			case corev1.LimitTypePersistentVolumeClaim:
+				limit, ok := item.Max[corev1.ResourceStorage]
+				want := workspaceFootprint[corev1.ResourceRequestsStorage]
+				if ok && limit.Cmp(want) < 0 {
+					problems = append(problems, fmt.Sprintf("LimitRange %q limits PersistentVolumeClaim storage to %s, but a workspace requests %s",
+						lr.Name, limit.String(), want.String()))
+				}
+			}
+		}
+	}
+	return problems
+}
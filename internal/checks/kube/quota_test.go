@@ -0,0 +1,167 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckResourceQuota(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name       string
+		Objects    []runtime.Object
+		Workspaces int
+		F          func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name:       "no quotas",
+			Objects:    nil,
+			Workspaces: DefaultWorkspaces,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `namespace "default" has no ResourceQuotas or LimitRanges`, result.Summary)
+			},
+		},
+		{
+			Name: "quota fits",
+			Objects: []runtime.Object{
+				resourceQuota("team", corev1.ResourceList{
+					corev1.ResourcePods:        resource.MustParse("20"),
+					corev1.ResourceRequestsCPU: resource.MustParse("20"),
+					corev1.ResourceLimitsCPU:   resource.MustParse("1"),
+				}, corev1.ResourceList{
+					corev1.ResourcePods: resource.MustParse("3"),
+				}),
+			},
+			Workspaces: 10,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `namespace "default" has quota for the control plane and 10 workspaces`, result.Summary)
+				assert.Equal(t, "usage", []quotaUsage{
+					{Quota: "team", Resource: "pods", Hard: "20", Used: "3", Remaining: "17", Required: "12"},
+					{Quota: "team", Resource: "requests.cpu", Hard: "20", Used: "0", Remaining: "20", Required: "10500m"},
+				}, result.Details["quotas"])
+			},
+		},
+		{
+			Name: "workspaces exhaust quota",
+			Objects: []runtime.Object{
+				resourceQuota("team", corev1.ResourceList{
+					corev1.ResourceMemory:          resource.MustParse("16Gi"),
+					corev1.ResourceRequestsStorage: resource.MustParse("100Gi"),
+				}, corev1.ResourceList{
+					corev1.ResourceMemory: resource.MustParse("4Gi"),
+				}),
+			},
+			Workspaces: 10,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", `quota "team" has 100Gi requests.storage remaining, enough for the control plane and 9 of 10 workspaces; `+
+					`quota "team" has 12Gi memory remaining, enough for the control plane and 5 of 10 workspaces`, result.Summary)
+			},
+		},
+		{
+			Name: "control plane does not fit",
+			Objects: []runtime.Object{
+				resourceQuota("team", corev1.ResourceList{
+					corev1.ResourcePersistentVolumeClaims: resource.MustParse("2"),
+				}, corev1.ResourceList{
+					corev1.ResourcePersistentVolumeClaims: resource.MustParse("2"),
+				}),
+			},
+			Workspaces: 0,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", `quota "team" has 0 persistentvolumeclaims remaining, but the control plane requires 1`, result.Summary)
+				assert.Equal(t, "docs", resourceQuotaDocsURL, result.DocsURL)
+			},
+		},
+		{
+			Name: "limit range",
+			Objects: []runtime.Object{
+				&corev1.LimitRange{
+					ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "limits"},
+					Spec: corev1.LimitRangeSpec{Limits: []corev1.LimitRangeItem{
+						{Type: corev1.LimitTypeContainer, Max: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")}},
+						{Type: corev1.LimitTypePersistentVolumeClaim, Max: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("5Gi")}},
+						{Type: corev1.LimitTypePod, Max: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")}},
+					}},
+				},
+			},
+			Workspaces: 10,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", `LimitRange "limits" limits PersistentVolumeClaim storage to 5Gi, but a workspace requests 10Gi; `+
+					`LimitRange "limits" limits container cpu to 500m, but a workspace requests 1`, result.Summary)
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client, WithWorkspaces(test.Workspaces))
+			test.F(t, checker.CheckResourceQuota(context.Background()))
+		})
+	}
+}
+
+func Test_CheckResourceQuota_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("list limit ranges", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "limitranges", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckResourceQuota(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "ouch")
+	})
+
+	for _, resource := range []string{"resourcequotas", "limitranges"} {
+		resource := resource
+		t.Run(resource+" forbidden", func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			client.Fake.PrependReactor("list", resource, func(action k8stesting.Action) (bool, runtime.Object, error) {
+				return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: resource}, "", xerrors.New("RBAC denied"))
+			})
+
+			result := NewKubernetesChecker(client).CheckResourceQuota(context.Background())
+			assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+			assert.Equal(t, "summary", `unable to check quotas without permission to list `+resource+` in namespace "default"`, result.Summary)
+			assert.True(t, "remediation", result.Remediation != "")
+		})
+	}
+}
+
+func resourceQuota(name string, hard, used corev1.ResourceList) *corev1.ResourceQuota {
+	return &corev1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+		Status:     corev1.ResourceQuotaStatus{Hard: hard, Used: used},
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"sync"
 
 	"golang.org/x/xerrors"
 
@@ -24,11 +25,23 @@ import (
 
 var errSelfSubjectRulesReviewNotSupported = xerrors.New("cluster does not support SelfSubjectRulesReview")
 
+// rbacDocsURL documents Kubernetes RBAC authorization.
+const rbacDocsURL = "https://kubernetes.io/docs/reference/access-authn-authz/rbac/"
+
 // CheckRBAC checks the cluster for the RBAC permissions required by Coder.
 // It will attempt to first use a SelfSubjectRulesReview to determine the capabilities
 // of the user. If this fails (notably on GKE), fall back to using SelfSubjectAccessRequests
-// which is slower but is more likely to work.
+// which is slower but is more likely to work. If a subject is configured, its permissions
+// are checked using SubjectAccessReviews instead.
 func (k *KubernetesChecker) CheckRBAC(ctx context.Context) []*api.CheckResult {
+	k.rbacGaps = nil
+
+	if k.subject != nil {
+		// There is no equivalent of SelfSubjectRulesReview for other
+		// subjects, so review each permission individually.
+		return k.checkRBACFallback(ctx)
+	}
+
 	ssrrResults, err := k.checkRBACDefault(ctx)
 	if err == nil {
 		return ssrrResults
@@ -81,10 +94,12 @@ func (k *KubernetesChecker) checkRBACDefault(ctx context.Context) ([]*api.CheckR
 	}
 
 	// TODO: optimize this
-	for req, reqVerbs := range k.reqs.ResourceRequirements {
+	for _, req := range k.reqs.ResourceRequirements.Keys() {
+		reqVerbs := k.reqs.ResourceRequirements[req]
 		if err := satisfies(req, reqVerbs, compactRules); err != nil {
 			summary := fmt.Sprintf("resource %s: %s", req.Resource, err)
-			results = append(results, api.ErrorResult(checkName, summary, err))
+			missing := missingVerbs(req, reqVerbs, compactRules)
+			results = append(results, k.rbacErrorResult(checkName, summary, req, missing, err))
 			continue
 		}
 		resourceName := req.Resource
@@ -96,10 +111,12 @@ func (k *KubernetesChecker) checkRBACDefault(ctx context.Context) ([]*api.CheckR
 	}
 
 	// TODO: remove this when the helm chart is fixed
-	for req, reqVerbs := range k.reqs.RoleOnlyResourceRequirements {
+	for _, req := range k.reqs.RoleOnlyResourceRequirements.Keys() {
+		reqVerbs := k.reqs.RoleOnlyResourceRequirements[req]
 		if err := satisfies(req, reqVerbs, compactRules); err != nil {
 			summary := fmt.Sprintf("resource %s: %s", req.Resource, err)
-			results = append(results, api.ErrorResult(checkName, summary, err))
+			missing := missingVerbs(req, reqVerbs, compactRules)
+			results = append(results, k.rbacErrorResult(checkName, summary, req, missing, err))
 			continue
 		}
 		resourceName := req.Resource
@@ -113,15 +130,79 @@ func (k *KubernetesChecker) checkRBACDefault(ctx context.Context) ([]*api.CheckR
 	return results, nil
 }
 
-func satisfies(req *ResourceRequirement, verbs ResourceVerbs, rules []rbacv1.PolicyRule) error {
-	for _, rule := range rules {
-		if apiGroupsMatch(req.Group, rule.APIGroups) &&
-			apiResourceMatch(req.Resource, rule.Resources) &&
-			verbsMatch(verbs, rule.Verbs) {
-			return nil
+func satisfies(req ResourceRequirement, verbs ResourceVerbs, rules []rbacv1.PolicyRule) error {
+	missing := missingVerbs(req, verbs, rules)
+	if len(missing) == 0 {
+		return nil
+	}
+	return xerrors.Errorf("not satisfied: group:%q resource:%q version:%q verbs:%+v missing:%+v", req.Group, req.Resource, req.Version, verbs, missing)
+}
+
+// missingVerbs returns the verbs on the required resource that are not
+// granted by any of the given rules.
+func missingVerbs(req ResourceRequirement, verbs ResourceVerbs, rules []rbacv1.PolicyRule) ResourceVerbs {
+	missing := ResourceVerbs{}
+	for _, verb := range verbs {
+		granted := false
+		for _, rule := range rules {
+			if apiGroupsMatch(req.Group, rule.APIGroups) &&
+				apiResourceMatch(req.Resource, rule.Resources) &&
+				verbsMatch(ResourceVerbs{verb}, rule.Verbs) {
+				granted = true
+				break
+			}
+		}
+		if !granted {
+			missing = append(missing, verb)
 		}
 	}
-	return xerrors.Errorf("not satisfied: group:%q resource:%q version:%q verbs:%+v", req.Group, req.Resource, req.Version, verbs)
+	return missing
+}
+
+// rbacErrorResult returns a failed result for a requirement, with a
+// remediation that grants the missing verbs. The missing verbs are also
+// recorded for RBACManifest.
+func (k *KubernetesChecker) rbacErrorResult(checkName, summary string, req ResourceRequirement, missing ResourceVerbs, err error) *api.CheckResult {
+	result := api.ErrorResult(checkName, summary, err)
+	if k.subject != nil {
+		result.Details["subject"] = k.subjectUser
+	}
+	if len(missing) > 0 {
+		k.recordRBACGap(req, missing)
+		result.Remediation = rbacRemediation(k.namespace, req, missing, k.subjectFlag())
+		result.DocsURL = rbacDocsURL
+	}
+	return result
+}
+
+// subjectFlag returns the kubectl flag used to bind a role to the
+// subject being checked.
+func (k *KubernetesChecker) subjectFlag() string {
+	if k.subject == nil {
+		return "--user=<user>"
+	}
+	if k.subject.Kind == rbacv1.ServiceAccountKind {
+		return fmt.Sprintf("--serviceaccount=%s:%s", k.subject.Namespace, k.subject.Name)
+	}
+	return "--user=" + k.subject.Name
+}
+
+// rbacRemediation returns the kubectl commands that create a role
+// granting the given verbs on the required resource, and bind it to
+// the subject given by subjectFlag.
+func rbacRemediation(namespace string, req ResourceRequirement, verbs ResourceVerbs, subjectFlag string) string {
+	name := "coder-" + strings.NewReplacer("/", "-", ".", "-").Replace(req.qualifiedResource())
+	verbList := strings.Join(verbs, ",")
+
+	if req.clusterScoped() {
+		return fmt.Sprintf("kubectl create clusterrole %s --verb=%s --resource=%s\n"+
+			"kubectl create clusterrolebinding %s --clusterrole=%s %s",
+			name, verbList, req.qualifiedResource(), name, name, subjectFlag)
+	}
+
+	return fmt.Sprintf("kubectl create role %s --namespace=%s --verb=%s --resource=%s\n"+
+		"kubectl create rolebinding %s --namespace=%s --role=%s %s",
+		name, namespace, verbList, req.qualifiedResource(), name, namespace, name, subjectFlag)
 }
 
 // The below adapted from k8s.io/pkg/apis/rbac/v1/evaluation_helpers.go
@@ -164,76 +245,176 @@ func apiResourceMatch(want string, have []string) bool {
 	return false
 }
 
-// checkRBACFallback uses a SelfSubjectAccessRequest to check the cluster for the required
-// accesses. This requires a number of checks and is relatively slow.
+// checkRBACFallback uses a SelfSubjectAccessRequest (or a SubjectAccessReview, if a subject
+// is configured) to check the cluster for the required accesses. This requires a number of
+// checks and is relatively slow.
 func (k *KubernetesChecker) checkRBACFallback(ctx context.Context) []*api.CheckResult {
 	const checkName = "kubernetes-rbac"
 	authClient := k.client.AuthorizationV1()
-	results := make([]*api.CheckResult, 0)
-
-	for req, reqVerbs := range k.reqs.ResourceRequirements {
-		if err := k.checkOneRBACSSAR(ctx, authClient, req, reqVerbs); err != nil {
-			summary := fmt.Sprintf("missing permissions on resource %s: %s", req.Resource, err)
-			results = append(results, api.ErrorResult(checkName, summary, err))
-			continue
-		}
 
-		summary := fmt.Sprintf("%s: can %s", req.Resource, strings.Join(reqVerbs, ", "))
-		results = append(results, api.PassResult(checkName, summary))
+	type rbacCheck struct {
+		req   ResourceRequirement
+		verbs ResourceVerbs
+	}
+	checks := make([]rbacCheck, 0, len(k.reqs.ResourceRequirements)+len(k.reqs.RoleOnlyResourceRequirements))
+	for _, req := range k.reqs.ResourceRequirements.Keys() {
+		checks = append(checks, rbacCheck{req: req, verbs: k.reqs.ResourceRequirements[req]})
 	}
 
 	// TODO: delete this when the enterprise-helm role no longer requests resources on things
 	// that don't exist.
-	for req, reqVerbs := range k.reqs.RoleOnlyResourceRequirements {
-		if err := k.checkOneRBACSSAR(ctx, authClient, req, reqVerbs); err != nil {
-			summary := fmt.Sprintf("missing permissions on resource %s: %s", req.Resource, err)
-			results = append(results, api.ErrorResult(checkName, summary, err))
+	for _, req := range k.reqs.RoleOnlyResourceRequirements.Keys() {
+		checks = append(checks, rbacCheck{req: req, verbs: k.reqs.RoleOnlyResourceRequirements[req]})
+	}
+
+	// Perform one review per verb, so that the reviews for a single
+	// resource are also spread across workers.
+	reviews := make([]rbacReview, 0)
+	for _, check := range checks {
+		for _, verb := range check.verbs {
+			reviews = append(reviews, rbacReview{req: check.req, verb: verb})
+		}
+	}
+	k.runRBACReviews(ctx, authClient, reviews)
+
+	results := make([]*api.CheckResult, 0, len(checks))
+	for _, check := range checks {
+		checkReviews := reviews[:len(check.verbs)]
+		reviews = reviews[len(check.verbs):]
+
+		if missing, err := rbacReviewsOutcome(check.verbs, checkReviews); err != nil {
+			summary := fmt.Sprintf("missing permissions on resource %s: %s", check.req.Resource, err)
+			results = append(results, k.rbacErrorResult(checkName, summary, check.req, missing, err))
 			continue
 		}
 
-		summary := fmt.Sprintf("%s: can %s", req.Resource, strings.Join(reqVerbs, ", "))
-		results = append(results, api.PassResult(checkName, summary))
+		summary := fmt.Sprintf("%s: can %s", check.req.Resource, strings.Join(check.verbs, ", "))
+		results = append(results, k.rbacPassResult(checkName, summary))
 	}
 
 	return results
 }
 
-func (k *KubernetesChecker) checkOneRBACSSAR(ctx context.Context, authClient authorizationv1client.AuthorizationV1Interface, req *ResourceRequirement, reqVerbs ResourceVerbs) error {
-	have := make([]string, 0, len(reqVerbs))
-	for _, verb := range reqVerbs {
-		sar := &authorizationv1.SelfSubjectAccessReview{
-			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
-				ResourceAttributes: &authorizationv1.ResourceAttributes{
+// rbacReview is a single access review performed by checkRBACFallback,
+// and its outcome.
+type rbacReview struct {
+	req     ResourceRequirement
+	verb    string
+	allowed bool
+	err     error
+}
+
+// runRBACReviews performs the given reviews using at most k.concurrency
+// concurrent requests, storing the outcome of each in place. Requests are
+// still subject to the client's rate limiter. Reviews not yet started
+// when the context is cancelled fail with the context's error.
+func (k *KubernetesChecker) runRBACReviews(ctx context.Context, authClient authorizationv1client.AuthorizationV1Interface, reviews []rbacReview) {
+	workers := k.concurrency
+	if workers > len(reviews) {
+		workers = len(reviews)
+	}
+
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for idx := range indices {
+				review := &reviews[idx]
+				if err := ctx.Err(); err != nil {
+					review.err = xerrors.Errorf("review access: %w", err)
+					continue
+				}
+				review.allowed, review.err = k.reviewAccess(ctx, authClient, &authorizationv1.ResourceAttributes{
 					Namespace: k.namespace,
-					Group:     req.Group,
-					Resource:  req.Resource,
-					Verb:      verb,
-				},
-			},
-		}
+					Group:     review.req.Group,
+					Resource:  review.req.Resource,
+					Verb:      review.verb,
+				})
+			}
+		}()
+	}
 
-		response, err := authClient.SelfSubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	for idx := range reviews {
+		indices <- idx
+	}
+	close(indices)
+	wg.Wait()
+}
 
-		if err != nil {
-			// should not fail - short-circuit
-			return xerrors.Errorf("failed to create SelfSubjectAccessReview request: %w", err)
+// rbacPassResult returns a passing result, noting the subject that was
+// checked if it is not the user running the checks.
+func (k *KubernetesChecker) rbacPassResult(checkName, summary string) *api.CheckResult {
+	if k.subject != nil {
+		summary = fmt.Sprintf("%s (as %s)", summary, k.subjectUser)
+	}
+	result := api.PassResult(checkName, summary)
+	if k.subject != nil {
+		result.Details["subject"] = k.subjectUser
+	}
+	return result
+}
+
+// rbacReviewsOutcome returns the verbs on a resource that the user is not
+// permitted to perform, given the reviews of each of the required verbs,
+// and an error if any are missing or a review could not be completed.
+func rbacReviewsOutcome(reqVerbs ResourceVerbs, reviews []rbacReview) (ResourceVerbs, error) {
+	have := make([]string, 0, len(reqVerbs))
+	missing := ResourceVerbs{}
+	for _, review := range reviews {
+		if review.err != nil {
+			return nil, review.err
 		}
 
-		if response.Status.Allowed {
-			have = append(have, verb)
+		if review.allowed {
+			have = append(have, review.verb)
 			continue
 		}
+		missing = append(missing, review.verb)
 	}
 
 	if len(have) != len(reqVerbs) {
-		return xerrors.Errorf(fmt.Sprintf("need: %+v have: %+v", reqVerbs, have))
+		return missing, xerrors.Errorf("need: %+v have: %+v", reqVerbs, have)
 	}
 
-	return nil
+	return nil, nil
+}
+
+// reviewAccess returns whether the user running the checks, or the
+// configured subject if any, may perform the given action.
+func (k *KubernetesChecker) reviewAccess(ctx context.Context, authClient authorizationv1client.AuthorizationV1Interface, attrs *authorizationv1.ResourceAttributes) (bool, error) {
+	if k.subject != nil {
+		sar := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				ResourceAttributes: attrs,
+				User:               k.subjectUser,
+				Groups:             k.subjectGroups,
+			},
+		}
+
+		response, err := authClient.SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+		if err != nil {
+			return false, xerrors.Errorf("failed to create SubjectAccessReview request: %w", err)
+		}
+		return response.Status.Allowed, nil
+	}
+
+	ssar := &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: attrs,
+		},
+	}
+
+	response, err := authClient.SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+	if err != nil {
+		return false, xerrors.Errorf("failed to create SelfSubjectAccessReview request: %w", err)
+	}
+	return response.Status.Allowed, nil
 }
 
-func findClosestVersionRequirements(v *semver.Version) *VersionedResourceRequirements {
-	for _, vreqs := range allRequirements {
+func findClosestVersionRequirements(all []VersionedResourceRequirements, v *semver.Version) *VersionedResourceRequirements {
+	for _, vreqs := range all {
 		if vreqs.VersionConstraints.Check(v) {
 			return &vreqs
 		}
@@ -0,0 +1,204 @@
+package kube
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	rbacutil "k8s.io/kubectl/pkg/util/rbac"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacManifestName is the name of the roles and bindings created by
+// RBACManifest.
+const rbacManifestName = "coder-install"
+
+// placeholderSubjectName is the name of the subject returned by
+// PlaceholderSubject.
+const placeholderSubjectName = "REPLACE-WITH-USER"
+
+// PlaceholderSubject returns a subject to bind the roles written by
+// RBACManifest to when the user cannot be determined. RBACManifest marks
+// it with a comment so it is replaced before the manifest is applied.
+func PlaceholderSubject() rbacv1.Subject {
+	return rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: placeholderSubjectName}
+}
+
+// UserSubject returns the subject for a Kubernetes username, which is a
+// service account if the name has the form
+// system:serviceaccount:namespace:name.
+func UserSubject(username string) rbacv1.Subject {
+	if parts := strings.Split(username, ":"); len(parts) == 4 && parts[0] == "system" && parts[1] == "serviceaccount" {
+		return rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: parts[2], Name: parts[3]}
+	}
+	return rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: username}
+}
+
+// selfSubjectReviewVersions are the versions of the SelfSubjectReview API,
+// which is not in this version of client-go, from newest to oldest.
+var selfSubjectReviewVersions = []string{"v1", "v1beta1", "v1alpha1"}
+
+// CurrentUser returns the username of the current user, as reported by a
+// SelfSubjectReview. It returns an error if the cluster does not serve
+// SelfSubjectReviews, which were added in Kubernetes 1.26.
+func (k *KubernetesChecker) CurrentUser(ctx context.Context) (string, error) {
+	rc := k.client.Discovery().RESTClient()
+	if rc == nil {
+		return "", xerrors.New("no REST client available")
+	}
+
+	for _, version := range selfSubjectReviewVersions {
+		body, err := json.Marshal(map[string]string{
+			"apiVersion": "authentication.k8s.io/" + version,
+			"kind":       "SelfSubjectReview",
+		})
+		if err != nil {
+			return "", xerrors.Errorf("encode selfsubjectreview: %w", err)
+		}
+
+		raw, err := rc.Post().
+			AbsPath("/apis/authentication.k8s.io", version, "selfsubjectreviews").
+			SetHeader("Content-Type", "application/json").
+			Body(body).
+			DoRaw(ctx)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return "", xerrors.Errorf("create selfsubjectreview: %w", err)
+		}
+
+		var review struct {
+			Status struct {
+				UserInfo struct {
+					Username string `json:"username"`
+				} `json:"userInfo"`
+			} `json:"status"`
+		}
+		if err := json.Unmarshal(raw, &review); err != nil {
+			return "", xerrors.Errorf("decode selfsubjectreview: %w", err)
+		}
+		if review.Status.UserInfo.Username == "" {
+			return "", xerrors.New("selfsubjectreview returned no username")
+		}
+		return review.Status.UserInfo.Username, nil
+	}
+
+	return "", xerrors.New("cluster does not serve SelfSubjectReviews")
+}
+
+// rbacGap records verbs on a required resource that the user is not
+// permitted to perform.
+type rbacGap struct {
+	req   ResourceRequirement
+	verbs ResourceVerbs
+}
+
+// recordRBACGap records missing permissions found by CheckRBAC, for use
+// by RBACManifest.
+func (k *KubernetesChecker) recordRBACGap(req ResourceRequirement, verbs ResourceVerbs) {
+	k.rbacGaps = append(k.rbacGaps, rbacGap{req: req, verbs: verbs})
+}
+
+// RBACManifest returns a YAML manifest that grants the given subject
+// the permissions found to be missing by CheckRBAC. It contains a Role
+// and RoleBinding in the checker's namespace and, if any cluster-scoped
+// permissions are missing, a ClusterRole and ClusterRoleBinding. The
+// rules are compacted to keep the manifest minimal. RBACManifest returns
+// nil if CheckRBAC has not found any missing permissions. If subject is
+// PlaceholderSubject, the manifest starts with a comment asking for it to
+// be replaced.
+func (k *KubernetesChecker) RBACManifest(subject rbacv1.Subject) ([]byte, error) {
+	if len(k.rbacGaps) == 0 {
+		return nil, nil
+	}
+
+	var namespaced, clusterScoped []rbacv1.PolicyRule
+	for _, gap := range k.rbacGaps {
+		rule := rbacv1.PolicyRule{
+			APIGroups: []string{gap.req.Group},
+			Resources: []string{gap.req.Resource},
+			Verbs:     gap.verbs,
+		}
+		if gap.req.clusterScoped() {
+			clusterScoped = append(clusterScoped, rbacutil.BreakdownRule(rule)...)
+		} else {
+			namespaced = append(namespaced, rbacutil.BreakdownRule(rule)...)
+		}
+	}
+
+	objects := make([]interface{}, 0, 4)
+	if len(namespaced) > 0 {
+		rules, err := compactRules(namespaced)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacManifestName, Namespace: k.namespace},
+				Rules:      rules,
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacManifestName, Namespace: k.namespace},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: rbacManifestName},
+				Subjects:   []rbacv1.Subject{subject},
+			},
+		)
+	}
+
+	if len(clusterScoped) > 0 {
+		rules, err := compactRules(clusterScoped)
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects,
+			&rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacManifestName},
+				Rules:      rules,
+			},
+			&rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: rbacManifestName},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: rbacManifestName},
+				Subjects:   []rbacv1.Subject{subject},
+			},
+		)
+	}
+
+	var buf bytes.Buffer
+	if subject == PlaceholderSubject() {
+		_, _ = fmt.Fprintf(&buf, "# The user could not be determined. Replace %s with the user to grant\n"+
+			"# these permissions to before applying this manifest.\n", placeholderSubjectName)
+	}
+	for i, obj := range objects {
+		out, err := yaml.Marshal(obj)
+		if err != nil {
+			return nil, xerrors.Errorf("marshal rbac manifest: %w", err)
+		}
+		if i > 0 {
+			_, _ = buf.WriteString("---\n")
+		}
+		_, _ = buf.Write(out)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func compactRules(rules []rbacv1.PolicyRule) ([]rbacv1.PolicyRule, error) {
+	compacted, err := rbacutil.CompactRules(rules)
+	if err != nil {
+		return nil, xerrors.Errorf("compact rules: %w", err)
+	}
+	sort.Stable(rbacutil.SortableRuleSlice(compacted))
+	return compacted, nil
+}
@@ -0,0 +1,186 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/yaml"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func Test_RBACManifest(t *testing.T) {
+	t.Parallel()
+
+	subject := rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "jane"}
+
+	t.Run("no gaps", func(t *testing.T) {
+		t.Parallel()
+
+		checker := NewKubernetesChecker(fake.NewSimpleClientset())
+		manifest, err := checker.RBACManifest(subject)
+		assert.Success(t, "generate manifest", err)
+		assert.True(t, "manifest should be empty", manifest == nil)
+	})
+
+	t.Run("nothing allowed", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &selfSubjectRulesReviewEmpty, nil
+		})
+
+		checker := NewKubernetesChecker(client, WithNamespace("coder"))
+		_ = checker.CheckRBAC(context.Background())
+
+		manifest, err := checker.RBACManifest(subject)
+		assert.Success(t, "generate manifest", err)
+
+		docs := strings.Split(string(manifest), "---\n")
+		assert.Equal(t, "manifest should contain four objects", 4, len(docs))
+
+		var role rbacv1.Role
+		err = yaml.Unmarshal([]byte(docs[0]), &role)
+		assert.Success(t, "unmarshal role", err)
+		assert.Equal(t, "role kind", "Role", role.Kind)
+		assert.Equal(t, "role namespace", "coder", role.Namespace)
+		for _, rule := range role.Rules {
+			assert.False(t, "role should not contain cluster-scoped resources",
+				rule.APIGroups[0] == "storage.k8s.io" && rule.Resources[0] == "storageclasses")
+		}
+
+		var binding rbacv1.RoleBinding
+		err = yaml.Unmarshal([]byte(docs[1]), &binding)
+		assert.Success(t, "unmarshal role binding", err)
+		assert.Equal(t, "binding subject", []rbacv1.Subject{subject}, binding.Subjects)
+		assert.Equal(t, "binding role", role.Name, binding.RoleRef.Name)
+
+		var clusterRole rbacv1.ClusterRole
+		err = yaml.Unmarshal([]byte(docs[2]), &clusterRole)
+		assert.Success(t, "unmarshal cluster role", err)
+		assert.Equal(t, "cluster role rules", []rbacv1.PolicyRule{
+			{
+				APIGroups: []string{"storage.k8s.io"},
+				Resources: []string{"storageclasses"},
+				Verbs:     verbsGetListWatch,
+			},
+		}, clusterRole.Rules)
+
+		// Running the checks again should not duplicate rules.
+		_ = checker.CheckRBAC(context.Background())
+		again, err := checker.RBACManifest(subject)
+		assert.Success(t, "generate manifest again", err)
+		assert.Equal(t, "manifest is unchanged", string(manifest), string(again))
+		assert.False(t, "no placeholder comment", strings.HasPrefix(string(manifest), "#"))
+	})
+
+	t.Run("placeholder subject", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, &selfSubjectRulesReviewEmpty, nil
+		})
+
+		checker := NewKubernetesChecker(client, WithNamespace("coder"))
+		_ = checker.CheckRBAC(context.Background())
+
+		manifest, err := checker.RBACManifest(PlaceholderSubject())
+		assert.Success(t, "generate manifest", err)
+		assert.True(t, "placeholder comment", strings.HasPrefix(string(manifest),
+			"# The user could not be determined. Replace REPLACE-WITH-USER with the user to grant\n"))
+
+		var binding rbacv1.RoleBinding
+		err = yaml.Unmarshal([]byte(strings.Split(string(manifest), "---\n")[1]), &binding)
+		assert.Success(t, "unmarshal role binding", err)
+		assert.Equal(t, "binding subject", "REPLACE-WITH-USER", binding.Subjects[0].Name)
+	})
+}
+
+func Test_UserSubject(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "user", rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "jane@example.com"},
+		UserSubject("jane@example.com"))
+	assert.Equal(t, "service account", rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Namespace: "coder", Name: "deployer"},
+		UserSubject("system:serviceaccount:coder:deployer"))
+	assert.Equal(t, "system user", rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "system:admin"},
+		UserSubject("system:admin"))
+}
+
+func Test_CurrentUser(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name string
+		// Versions are the SelfSubjectReview versions served.
+		Versions []string
+		Username string
+		Error    string
+	}{
+		{
+			Name:     "v1",
+			Versions: []string{"v1", "v1beta1"},
+			Username: "jane",
+		},
+		{
+			Name:     "v1beta1",
+			Versions: []string{"v1beta1"},
+			Username: "jane",
+		},
+		{
+			Name:     "not served",
+			Versions: nil,
+			Error:    "cluster does not serve SelfSubjectReviews",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				for _, version := range test.Versions {
+					if req.Method == http.MethodPost && req.URL.Path == "/apis/authentication.k8s.io/"+version+"/selfsubjectreviews" {
+						w.Header().Set("Content-Type", "application/json")
+						w.WriteHeader(http.StatusCreated)
+						err := json.NewEncoder(w).Encode(map[string]interface{}{
+							"apiVersion": "authentication.k8s.io/" + version,
+							"kind":       "SelfSubjectReview",
+							"status": map[string]interface{}{
+								"userInfo": map[string]interface{}{"username": test.Username, "groups": []string{"system:authenticated"}},
+							},
+						})
+						assert.Success(t, "failed to encode response", err)
+						return
+					}
+				}
+				w.WriteHeader(http.StatusNotFound)
+			}))
+			defer server.Close()
+
+			client, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+			assert.Success(t, "failed to create client", err)
+
+			username, err := NewKubernetesChecker(client).CurrentUser(context.Background())
+			if test.Error != "" {
+				assert.ErrorContains(t, "error", err, test.Error)
+				return
+			}
+			assert.Success(t, "current user", err)
+			assert.Equal(t, "username", test.Username, username)
+		})
+	}
+}
@@ -2,7 +2,10 @@ package kube
 
 import (
 	"context"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"golang.org/x/xerrors"
 	authorizationv1 "k8s.io/api/authorization/v1"
@@ -58,6 +61,8 @@ func Test_CheckRBACFallback(t *testing.T) {
 				for _, result := range results {
 					assert.True(t, result.Name+" should have an error", result.Details["error"] != nil)
 					assert.True(t, result.Name+" should fail", result.State == api.StateFailed)
+					assert.True(t, result.Name+" should have a remediation", strings.Contains(result.Remediation, "kubectl create"))
+					assert.Equal(t, result.Name+" should link to docs", rbacDocsURL, result.DocsURL)
 				}
 			},
 		},
@@ -79,6 +84,10 @@ func Test_CheckRBACFallback(t *testing.T) {
 			checker := NewKubernetesChecker(client)
 			results := checker.checkRBACFallback(context.Background())
 			test.F(t, results)
+
+			// Results must be reported in the same order on every run.
+			again := checker.checkRBACFallback(context.Background())
+			assert.Equal(t, "results should have a stable order", summaries(results), summaries(again))
 		})
 	}
 }
@@ -101,6 +110,176 @@ func Test_CheckRBACFallback_ClientError(t *testing.T) {
 	}
 }
 
+func Test_CheckRBACFallback_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	var inFlight, maxInFlight int32
+	client := fake.NewSimpleClientset()
+	client.Fake.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(time.Millisecond)
+
+		ssar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		if ssar.Spec.ResourceAttributes.Resource == "secrets" {
+			return true, &selfSubjectAccessReviewDenied, nil
+		}
+		return true, &selfSubjectAccessReviewAllowed, nil
+	})
+
+	checker := NewKubernetesChecker(client, WithConcurrency(4))
+	results := checker.checkRBACFallback(context.Background())
+	assert.Equal(t, "should have one result per requirement",
+		len(checker.reqs.ResourceRequirements)+len(checker.reqs.RoleOnlyResourceRequirements), len(results))
+	assert.True(t, "should not exceed concurrency", atomic.LoadInt32(&maxInFlight) <= 4)
+
+	// Each result must match the reviews for its own resource.
+	for _, result := range results {
+		if strings.Contains(result.Summary, "resource secrets:") {
+			assert.True(t, result.Summary+" should fail", result.State == api.StateFailed)
+			continue
+		}
+		assert.True(t, result.Summary+" should pass", result.State == api.StatePassed)
+	}
+}
+
+func Test_CheckRBACFallback_Cancelled(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.Fake.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Error("should not review access after the context is cancelled")
+		return true, &selfSubjectAccessReviewAllowed, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	checker := NewKubernetesChecker(client)
+	results := checker.checkRBACFallback(ctx)
+	assert.False(t, "results should not be empty", len(results) == 0)
+	for _, result := range results {
+		assert.ErrorContains(t, result.Name+" should show correct error", result.Details["error"].(error), "context canceled")
+		assert.True(t, result.Name+" should fail", result.State == api.StateFailed)
+	}
+}
+
+func Test_CheckRBAC_ServiceAccount(t *testing.T) {
+	t.Parallel()
+
+	client := fake.NewSimpleClientset()
+	client.Fake.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		t.Error("should not review the rules of the current user")
+		return true, nil, xerrors.New("unexpected")
+	})
+	client.Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+		allowed := sar.Spec.User == "system:serviceaccount:coder:coder" &&
+			len(sar.Spec.Groups) == 3 &&
+			sar.Spec.ResourceAttributes.Verb != "delete"
+		return true, &authorizationv1.SubjectAccessReview{
+			Status: authorizationv1.SubjectAccessReviewStatus{Allowed: allowed},
+		}, nil
+	})
+
+	checker := NewKubernetesChecker(client, WithNamespace("coder"), WithServiceAccount("coder", "coder"))
+	subject, ok := checker.Subject()
+	assert.True(t, "subject should be set", ok)
+	assert.Equal(t, "subject kind", rbacv1.ServiceAccountKind, subject.Kind)
+
+	results := checker.CheckRBAC(context.Background())
+	assert.False(t, "results should not be empty", len(results) == 0)
+	for _, result := range results {
+		if result.State == api.StatePassed {
+			assert.Equal(t, result.Name+" should record the subject", "system:serviceaccount:coder:coder", result.Details["subject"])
+			continue
+		}
+		assert.True(t, result.Name+" should fail", result.State == api.StateFailed)
+		assert.True(t, result.Name+" should be missing delete", strings.Contains(result.Remediation, "--verb=delete"))
+		assert.True(t, result.Name+" should bind the service account", strings.Contains(result.Remediation, "--serviceaccount=coder:coder"))
+	}
+}
+
+func Test_CheckRBAC_Subject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name           string
+		Groups         []string
+		ExpectedGroups []string
+	}{
+		{
+			Name:           "no groups",
+			Groups:         nil,
+			ExpectedGroups: []string{"system:authenticated"},
+		},
+		{
+			Name:           "groups",
+			Groups:         []string{"developers"},
+			ExpectedGroups: []string{"developers", "system:authenticated"},
+		},
+		{
+			Name:           "already authenticated",
+			Groups:         []string{"system:authenticated", "developers"},
+			ExpectedGroups: []string{"system:authenticated", "developers"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			client.Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				sar := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SubjectAccessReview)
+				allowed := sar.Spec.User == "jane" &&
+					strings.Join(sar.Spec.Groups, ",") == strings.Join(test.ExpectedGroups, ",")
+				return true, &authorizationv1.SubjectAccessReview{
+					Status: authorizationv1.SubjectAccessReviewStatus{Allowed: allowed},
+				}, nil
+			})
+
+			checker := NewKubernetesChecker(client, WithSubject("jane", test.Groups))
+			results := checker.CheckRBAC(context.Background())
+			assert.False(t, "results should not be empty", len(results) == 0)
+			for _, result := range results {
+				assert.Equal(t, result.Name+" should pass with groups "+strings.Join(test.ExpectedGroups, ","), api.StatePassed, result.State)
+			}
+		})
+	}
+}
+
+func Test_CheckRBAC_SubjectClientError(t *testing.T) {
+	t.Parallel()
+	client := fake.NewSimpleClientset()
+	client.Fake.PrependReactor("create", "subjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, xerrors.New("ouch")
+	})
+
+	checker := NewKubernetesChecker(client, WithSubject("jane", []string{"developers"}))
+	results := checker.CheckRBAC(context.Background())
+	assert.False(t, "results should not be empty", len(results) == 0)
+	for _, result := range results {
+		assert.ErrorContains(t, result.Name+" should show correct error", result.Details["error"].(error), "failed to create SubjectAccessReview request")
+		assert.True(t, result.Name+" should fail", result.State == api.StateFailed)
+	}
+}
+
+func summaries(results []*api.CheckResult) []string {
+	out := make([]string, 0, len(results))
+	for _, result := range results {
+		out = append(out, result.Summary)
+	}
+	return out
+}
+
 var selfSubjectAccessReviewAllowed authorizationv1.SelfSubjectAccessReview = authorizationv1.SelfSubjectAccessReview{
 	Status: authorizationv1.SubjectAccessReviewStatus{
 		Allowed: true,
@@ -163,7 +342,7 @@ func Test_Satisfies(t *testing.T) {
 
 	tests := []struct {
 		Name        string
-		Requirement *ResourceRequirement
+		Requirement ResourceRequirement
 		Verbs       ResourceVerbs
 		Rules       []rbacv1.PolicyRule
 		Expected    *string
@@ -248,6 +427,37 @@ func Test_Satisfies(t *testing.T) {
 	}
 }
 
+func Test_MissingVerbs(t *testing.T) {
+	t.Parallel()
+
+	req := NewResourceRequirement("apps", "apps/v1", "deployments")
+	rules := []rbacv1.PolicyRule{
+		makeTestPolicyRule(ss("get"), ss("apps"), ss("deployments"), ss(), ss()),
+		makeTestPolicyRule(ss("list"), ss("apps"), ss("*"), ss(), ss()),
+		makeTestPolicyRule(ss("*"), ss(""), ss("deployments"), ss(), ss()),
+	}
+
+	missing := missingVerbs(req, ss("get", "list", "create"), rules)
+	assert.Equal(t, "verbs granted across rules are not missing", ResourceVerbs{"create"}, missing)
+	assert.Success(t, "verbs granted across rules are satisfied", satisfies(req, ss("get", "list"), rules))
+}
+
+func Test_RBACRemediation(t *testing.T) {
+	t.Parallel()
+
+	remediation := rbacRemediation("coder", NewResourceRequirement("apps", "apps/v1", "deployments"), ss("get", "create"), "--user=<user>")
+	assert.Equal(t, "namespaced remediation",
+		"kubectl create role coder-deployments-apps --namespace=coder --verb=get,create --resource=deployments.apps\n"+
+			"kubectl create rolebinding coder-deployments-apps --namespace=coder --role=coder-deployments-apps --user=<user>",
+		remediation)
+
+	remediation = rbacRemediation("coder", NewResourceRequirement("storage.k8s.io", "storage.k8s.io/v1", "storageclasses"), ss("list"), "--user=<user>")
+	assert.Equal(t, "cluster-scoped remediation",
+		"kubectl create clusterrole coder-storageclasses-storage-k8s-io --verb=list --resource=storageclasses.storage.k8s.io\n"+
+			"kubectl create clusterrolebinding coder-storageclasses-storage-k8s-io --clusterrole=coder-storageclasses-storage-k8s-io --user=<user>",
+		remediation)
+}
+
 var testNoPermRule = makeTestPolicyRule(ss(), ss(), ss(), ss(), ss())
 var testV1WildcardRule = makeTestPolicyRule(verbsAll, ss(""), ss("*"), ss(), ss())
 var testAppsV1WildcardRule = makeTestPolicyRule(verbsAll, ss("apps"), ss("*"), ss(), ss())
@@ -9,6 +9,21 @@ import (
 	"cdr.dev/coder-doctor/internal/api"
 )
 
+// metricsServerURL is the project page for metrics-server, which provides
+// the metrics.k8s.io API.
+const metricsServerURL = "https://github.com/kubernetes-sigs/metrics-server"
+
+// resourceRemediation returns the remediation and documentation link for
+// a resource that is not available in the cluster.
+func resourceRemediation(req ResourceRequirement) (string, string) {
+	if req.Group == "metrics.k8s.io" {
+		return "Install metrics-server in the cluster to provide the metrics.k8s.io API", metricsServerURL
+	}
+
+	return fmt.Sprintf("Enable the %s API in the cluster, or upgrade to a Kubernetes version supported by Coder", req.Version),
+		kubernetesVersionDocsURL
+}
+
 func (k *KubernetesChecker) CheckResources(_ context.Context) []*api.CheckResult {
 	const checkName = "kubernetes-resources"
 	results := make([]*api.CheckResult, 0)
@@ -44,9 +59,9 @@ func (k *KubernetesChecker) CheckResources(_ context.Context) []*api.CheckResult
 		}
 	}
 
-	for versionReq := range k.reqs.ResourceRequirements {
+	for _, versionReq := range k.reqs.ResourceRequirements.Keys() {
 		result := &api.CheckResult{
-			Summary: checkName,
+			Name: checkName,
 			Details: map[string]interface{}{
 				"resource":     versionReq.Resource,
 				"group":        versionReq.Group,
@@ -54,12 +69,13 @@ func (k *KubernetesChecker) CheckResources(_ context.Context) []*api.CheckResult
 			},
 		}
 
-		if resourcesAvailable[*versionReq] {
+		if resourcesAvailable[versionReq] {
 			result.Summary = fmt.Sprintf("Cluster supports %s resource %s", versionReq.Version, versionReq.Resource)
 			result.State = api.StatePassed
 		} else {
 			result.Summary = fmt.Sprintf("Cluster does not support %s resource %s", versionReq.Version, versionReq.Resource)
 			result.State = api.StateFailed
+			result.Remediation, result.DocsURL = resourceRemediation(versionReq)
 		}
 		results = append(results, result)
 	}
@@ -1,60 +1,34 @@
 package kube
 
 import (
+	"sort"
+
 	"github.com/Masterminds/semver/v3"
 
-	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 )
 
-// This is a list of all known Resource and/or RBAC requirements for each version of Coder.
-// Order by version DESCENDING.
-var allRequirements = []VersionedResourceRequirements{
-	{
-		VersionConstraints: api.MustConstraint(">= 1.20"),
-		ResourceRequirements: map[*ResourceRequirement]ResourceVerbs{
-			NewResourceRequirement("", "v1", "events"):                                                          verbsAll,
-			NewResourceRequirement("", "v1", "persistentvolumeclaims"):                                          verbsAll,
-			NewResourceRequirement("", "v1", "pods"):                                                            verbsAll,
-			NewResourceRequirement("", "v1", "secrets"):                                                         verbsAll,
-			NewResourceRequirement("", "v1", "serviceaccounts"):                                                 verbsAll,
-			NewResourceRequirement("", "v1", "services"):                                                        verbsAll,
-			NewResourceRequirement("apps", "apps/v1", "deployments"):                                            verbsAll,
-			NewResourceRequirement("apps", "apps/v1", "replicasets"):                                            verbsAll,
-			NewResourceRequirement("apps", "apps/v1", "statefulsets"):                                           verbsAll,
-			NewResourceRequirement("metrics.k8s.io", "metrics.k8s.io/v1beta1", "pods"):                          verbsGetListWatch,
-			NewResourceRequirement("networking.k8s.io", "networking.k8s.io/v1", "ingresses"):                    verbsAll,
-			NewResourceRequirement("networking.k8s.io", "networking.k8s.io/v1", "networkpolicies"):              verbsAll,
-			NewResourceRequirement("rbac.authorization.k8s.io", "rbac.authorization.k8s.io/v1", "roles"):        verbsGetCreate,
-			NewResourceRequirement("rbac.authorization.k8s.io", "rbac.authorization.k8s.io/v1", "rolebindings"): verbsGetCreate,
-			NewResourceRequirement("storage.k8s.io", "storage.k8s.io/v1", "storageclasses"):                     verbsGetListWatch,
-		},
-		RoleOnlyResourceRequirements: map[*ResourceRequirement]ResourceVerbs{
-			// The below permissions are required by the default coder role created by the Helm chart.
-			// Installation will fail if these are not present in the role being used to install Coder.
-			NewResourceRequirement("", "v1", "deployments"):                             verbsAll,
-			NewResourceRequirement("", "v1", "networkpolicies"):                         verbsAll,
-			NewResourceRequirement("", "v1", "pods/exec"):                               verbsAll,
-			NewResourceRequirement("", "v1", "pods/log"):                                verbsAll,
-			NewResourceRequirement("apps", "v1", "events"):                              verbsAll,
-			NewResourceRequirement("apps", "v1", "networkpolicies"):                     verbsAll,
-			NewResourceRequirement("apps", "v1", "persistentvolumeclaims"):              verbsAll,
-			NewResourceRequirement("apps", "v1", "pods"):                                verbsAll,
-			NewResourceRequirement("apps", "v1", "pods/exec"):                           verbsAll,
-			NewResourceRequirement("apps", "v1", "pods/log"):                            verbsAll,
-			NewResourceRequirement("apps", "v1", "secrets"):                             verbsAll,
-			NewResourceRequirement("apps", "v1", "services"):                            verbsAll,
-			NewResourceRequirement("metrics.k8s.io", "v1beta1", "storageclasses"):       verbsGetListWatch,
-			NewResourceRequirement("networking.k8s.io", "v1", "deployments"):            verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "events"):                 verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "persistentvolumeclaims"): verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "pods"):                   verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "pods/exec"):              verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "pods/log"):               verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "secrets"):                verbsAll,
-			NewResourceRequirement("networking.k8s.io", "v1", "services"):               verbsAll,
-			NewResourceRequirement("storage.k8s.io", "v1", "pods"):                      verbsGetListWatch,
-		},
-	},
+// resourceRequirementsFromManifest returns the resource and RBAC
+// requirements for each version of Coder listed in the manifest, ordered
+// by version descending.
+func resourceRequirementsFromManifest(m *requirements.Manifest) []VersionedResourceRequirements {
+	all := make([]VersionedResourceRequirements, 0, len(m.Resources))
+	for _, set := range m.Resources {
+		all = append(all, VersionedResourceRequirements{
+			VersionConstraints:           set.Constraint.Constraints,
+			ResourceRequirements:         resourceRequirementsFromList(set.Resources),
+			RoleOnlyResourceRequirements: resourceRequirementsFromList(set.RoleOnlyResources),
+		})
+	}
+	return all
+}
+
+func resourceRequirementsFromList(resources []requirements.Resource) ResourceRequirements {
+	reqs := make(ResourceRequirements, len(resources))
+	for _, r := range resources {
+		reqs[NewResourceRequirement(r.Group, r.Version, r.Resource)] = r.Verbs
+	}
+	return reqs
 }
 
 // ResourceRequirement describes a set of requirements on a specific version of a resource:
@@ -66,24 +40,70 @@ type ResourceRequirement struct {
 	Version  string
 }
 
+// less orders requirements by group, version and resource.
+func (r ResourceRequirement) less(other ResourceRequirement) bool {
+	if r.Group != other.Group {
+		return r.Group < other.Group
+	}
+	if r.Version != other.Version {
+		return r.Version < other.Version
+	}
+	return r.Resource < other.Resource
+}
+
+// qualifiedResource returns the resource name qualified with its API
+// group, as accepted by kubectl (e.g. "deployments.apps").
+func (r ResourceRequirement) qualifiedResource() string {
+	if r.Group == "" {
+		return r.Resource
+	}
+	return r.Resource + "." + r.Group
+}
+
+// clusterScopedResources contains the required resources that are not
+// namespaced, keyed by their qualified resource name.
+var clusterScopedResources = map[string]bool{
+	"namespaces":                    true,
+	"storageclasses.storage.k8s.io": true,
+}
+
+// clusterScoped returns true if the resource is not namespaced, and so
+// permissions for it must be granted with a ClusterRole.
+func (r ResourceRequirement) clusterScoped() bool {
+	return clusterScopedResources[r.qualifiedResource()]
+}
+
 type ResourceVerbs []string
 
+// ResourceRequirements maps required resources to the verbs the user must be
+// permitted to perform on them.
+type ResourceRequirements map[ResourceRequirement]ResourceVerbs
+
+// Keys returns the required resources sorted by group, version and resource,
+// so that results are reported in a stable order.
+func (r ResourceRequirements) Keys() []ResourceRequirement {
+	keys := make([]ResourceRequirement, 0, len(r))
+	for req := range r {
+		keys = append(keys, req)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	return keys
+}
+
 // VersionedResourceRequirements is a set of ResourceRequirements for a specific version of Coder.
 type VersionedResourceRequirements struct {
 	VersionConstraints   *semver.Constraints
-	ResourceRequirements map[*ResourceRequirement]ResourceVerbs
+	ResourceRequirements ResourceRequirements
 	// These are only required because the role in the Helm chart specifies broad swathes of permissions that
 	// don't necessarily exist in the real world.
-	RoleOnlyResourceRequirements map[*ResourceRequirement]ResourceVerbs
+	RoleOnlyResourceRequirements ResourceRequirements
 }
 
-var verbsAll ResourceVerbs = []string{"create", "delete", "deletecollection", "get", "list", "update", "patch", "watch"}
-var verbsGetListWatch = []string{"get", "list", "watch"}
-var verbsGetCreate = []string{"get", "create"}
-
 // NewResourceRequirement is just a convenience function for creating ResourceRequirements for which the resource type must exist.
-func NewResourceRequirement(apiGroup, version, resource string) *ResourceRequirement {
-	return &ResourceRequirement{
+func NewResourceRequirement(apiGroup, version, resource string) ResourceRequirement {
+	return ResourceRequirement{
 		Group:    apiGroup,
 		Resource: resource,
 		Version:  version,
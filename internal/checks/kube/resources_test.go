@@ -29,6 +29,7 @@ func Test_KubernetesChecker_CheckResources(t *testing.T) {
 			F: func(t *testing.T, results []*api.CheckResult) {
 				assert.False(t, "results should not be empty", len(results) == 0)
 				for _, result := range results {
+					assert.Equal(t, "result name matches", "kubernetes-resources", result.Name)
 					assert.False(t, result.Name+" should have a resource", len(result.Details["resource"].(string)) == 0)
 					assert.False(t, result.Name+" should have a groupVersion", len(result.Details["groupVersion"].(string)) == 0)
 					assert.Equal(t, result.Name+" should have no error", nil, result.Details["error"])
@@ -46,6 +47,7 @@ func Test_KubernetesChecker_CheckResources(t *testing.T) {
 					assert.False(t, result.Name+" should have a groupVersion", len(result.Details["groupVersion"].(string)) == 0)
 					assert.Equal(t, result.Name+" should have no error", nil, result.Details["error"])
 					assert.Equal(t, result.Name+" should pass", api.StateFailed, result.State)
+					assert.True(t, result.Name+" should have a remediation", result.Remediation != "")
 				}
 			},
 		},
@@ -194,3 +196,26 @@ func makeResourceList(version, groupVersion string, resources ...string) *metav1
 	}
 	return rl
 }
+
+func Test_ResourceRequirements_Keys(t *testing.T) {
+	t.Parallel()
+
+	reqs := ResourceRequirements{
+		NewResourceRequirement("apps", "apps/v1", "statefulsets"): verbsAll,
+		NewResourceRequirement("", "v1", "services"):              verbsAll,
+		NewResourceRequirement("apps", "apps/v1", "deployments"):  verbsAll,
+		NewResourceRequirement("", "v1", "pods"):                  verbsAll,
+		NewResourceRequirement("apps", "v1", "pods"):              verbsAll,
+	}
+
+	expected := []ResourceRequirement{
+		NewResourceRequirement("", "v1", "pods"),
+		NewResourceRequirement("", "v1", "services"),
+		NewResourceRequirement("apps", "apps/v1", "deployments"),
+		NewResourceRequirement("apps", "apps/v1", "statefulsets"),
+		NewResourceRequirement("apps", "v1", "pods"),
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "keys should be sorted", expected, reqs.Keys())
+	}
+}
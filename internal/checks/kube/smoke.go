@@ -0,0 +1,481 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	"cdr.dev/slog"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+const (
+	// DefaultSmokeTestTimeout is the default time to wait for the
+	// resources created by the smoke tests to become ready.
+	DefaultSmokeTestTimeout = 2 * time.Minute
+	// DefaultLoadBalancerSmokeTestTimeout is the default time to wait for
+	// a load balancer to be provisioned.
+	DefaultLoadBalancerSmokeTestTimeout = 5 * time.Minute
+
+	// smokeTestPrefix is the name prefix of resources created by the
+	// smoke tests.
+	smokeTestPrefix = "coder-doctor-smoke-"
+	// smokeTestRunLabel identifies the resources created by a single run
+	// of the smoke tests, so they can be cleaned up.
+	smokeTestRunLabel = "coder.com/doctor-smoke-test"
+	// smokeTestImage is a minimal image that runs until terminated.
+	smokeTestImage = "k8s.gcr.io/pause:3.5"
+	// smokeTestCleanupTimeout bounds the time spent deleting resources
+	// created by the smoke tests.
+	smokeTestCleanupTimeout = 30 * time.Second
+	// smokeTestPollInterval is the default interval between checks of
+	// the smoke test resources.
+	smokeTestPollInterval = time.Second
+)
+
+// CheckSmokeTests runs the enabled smoke tests in the checker's namespace.
+// The volume test creates a small PersistentVolumeClaim and a pod that
+// mounts it, and reports the time taken for the claim to be bound and the
+// pod to become ready. The load balancer test creates a LoadBalancer
+// Service, and reports the time taken for it to be provisioned. Each test
+// fails if this does not happen within its timeout. The resources are
+// always deleted, even if ctx is cancelled.
+func (k *KubernetesChecker) CheckSmokeTests(ctx context.Context) (results []*api.CheckResult) {
+	const (
+		loadBalancerCheckName = "kubernetes-smoke-loadbalancer"
+		cleanupCheckName      = "kubernetes-smoke-cleanup"
+	)
+
+	runID := rand.String(5)
+	name := smokeTestPrefix + runID
+	selector := labels.SelectorFromSet(labels.Set{smokeTestRunLabel: runID}).String()
+
+	defer func() {
+		if err := k.cleanupSmokeTest(selector); err != nil {
+			result := api.WarnResult(cleanupCheckName, "failed to delete smoke test resources")
+			result.Details = map[string]interface{}{
+				"error": api.NewError(err),
+			}
+			result.Remediation = fmt.Sprintf("Delete the remaining resources with: kubectl delete services,pods,pvc --namespace=%s --selector=%s",
+				k.namespace, selector)
+			results = append(results, result)
+		}
+	}()
+
+	// Load balancers are usually the slowest to provision, so the Service
+	// is created first, and waited for once the volume checks complete.
+	var (
+		lbCtx          context.Context
+		serviceErr     error
+		serviceCreated time.Time
+	)
+	if k.loadBalancerSmokeTest {
+		var cancel context.CancelFunc
+		lbCtx, cancel = context.WithTimeout(ctx, k.loadBalancerSmokeTestTimeout)
+		defer cancel()
+
+		_, serviceErr = k.client.CoreV1().Services(k.namespace).Create(lbCtx, smokeTestService(name, runID), metav1.CreateOptions{})
+		serviceCreated = time.Now()
+	}
+
+	if k.smokeTests {
+		volumeCtx, cancel := context.WithTimeout(ctx, k.smokeTestTimeout)
+		defer cancel()
+
+		results = append(results, k.smokeTestVolume(volumeCtx, name, runID)...)
+	}
+
+	if !k.loadBalancerSmokeTest {
+		return results
+	}
+	if serviceErr != nil {
+		results = append(results, api.ErrorResult(loadBalancerCheckName, "failed to create LoadBalancer Service", serviceErr))
+	} else {
+		results = append(results, k.smokeTestLoadBalancer(lbCtx, loadBalancerCheckName, name, serviceCreated))
+	}
+	return results
+}
+
+// smokeTestVolume creates the PersistentVolumeClaim and the pod mounting
+// it, and waits for them to become ready. Each is timed from its creation.
+func (k *KubernetesChecker) smokeTestVolume(ctx context.Context, name, runID string) []*api.CheckResult {
+	const (
+		pvcCheckName = "kubernetes-smoke-pvc"
+		podCheckName = "kubernetes-smoke-pod"
+	)
+
+	pvc, err := k.client.CoreV1().PersistentVolumeClaims(k.namespace).Create(ctx, smokeTestPVC(name, runID), metav1.CreateOptions{})
+	if err != nil {
+		return []*api.CheckResult{
+			api.ErrorResult(pvcCheckName, "failed to create PersistentVolumeClaim", err),
+			api.SkippedResult(podCheckName, "PersistentVolumeClaim could not be created", nil),
+		}
+	}
+	pvcCreated := time.Now()
+
+	if _, err := k.client.CoreV1().Pods(k.namespace).Create(ctx, smokeTestPod(name, runID), metav1.CreateOptions{}); err != nil {
+		return []*api.CheckResult{
+			api.SkippedResult(pvcCheckName, "pod to mount PersistentVolumeClaim could not be created", nil),
+			api.ErrorResult(podCheckName, "failed to create pod", err),
+		}
+	}
+	podCreated := time.Now()
+
+	// Volumes of a StorageClass using WaitForFirstConsumer binding are only
+	// provisioned once the pod is scheduled, so wait for the claim after
+	// creating the pod.
+	err = wait.PollImmediateUntil(k.smokeTestInterval, func() (bool, error) {
+		current, err := k.client.CoreV1().PersistentVolumeClaims(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		pvc = current
+		return pvc.Status.Phase == corev1.ClaimBound, nil
+	}, ctx.Done())
+	bindDuration := time.Since(pvcCreated)
+
+	pvcResult := &api.CheckResult{
+		Name: pvcCheckName,
+		Details: map[string]interface{}{
+			"name":     name,
+			"phase":    string(pvc.Status.Phase),
+			"duration": bindDuration.Round(time.Millisecond).String(),
+		},
+	}
+	if pvc.Spec.StorageClassName != nil {
+		pvcResult.Details["storage-class"] = *pvc.Spec.StorageClassName
+	}
+	if pvc.Spec.VolumeName != "" {
+		pvcResult.Details["volume"] = pvc.Spec.VolumeName
+	}
+
+	if err != nil {
+		pvcResult.State = api.StateFailed
+		pvcResult.Summary = k.smokeTestFailure(ctx, "PersistentVolumeClaim", name, bindDuration, err)
+		pvcResult.Details["error"] = api.NewError(err)
+		if events := k.smokeTestEvents(ctx, "PersistentVolumeClaim", name); len(events) > 0 {
+			pvcResult.Details["events"] = events
+		}
+		pvcResult.Remediation = "Check that the storage provisioner for the default StorageClass is running, " +
+			"and see the events above for the reason the volume was not provisioned"
+		pvcResult.DocsURL = "https://kubernetes.io/docs/concepts/storage/dynamic-provisioning/"
+		return []*api.CheckResult{
+			pvcResult,
+			api.SkippedResult(podCheckName, "PersistentVolumeClaim was not bound", nil),
+		}
+	}
+
+	pvcResult.State = api.StatePassed
+	pvcResult.Summary = fmt.Sprintf("PersistentVolumeClaim %s was bound in %s", name, bindDuration.Round(time.Millisecond))
+
+	var pod *corev1.Pod
+	err = wait.PollImmediateUntil(k.smokeTestInterval, func() (bool, error) {
+		current, err := k.client.CoreV1().Pods(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		pod = current
+		if pod.Status.Phase == corev1.PodFailed || pod.Status.Phase == corev1.PodSucceeded {
+			return false, xerrors.Errorf("pod exited with phase %s: %s", pod.Status.Phase, pod.Status.Message)
+		}
+		return isPodReady(pod), nil
+	}, ctx.Done())
+	readyDuration := time.Since(podCreated)
+
+	podResult := &api.CheckResult{
+		Name: podCheckName,
+		Details: map[string]interface{}{
+			"name":     name,
+			"image":    smokeTestImage,
+			"duration": readyDuration.Round(time.Millisecond).String(),
+		},
+	}
+	if pod != nil {
+		podResult.Details["phase"] = string(pod.Status.Phase)
+		if pod.Spec.NodeName != "" {
+			podResult.Details["node"] = pod.Spec.NodeName
+		}
+	}
+
+	if err != nil {
+		podResult.State = api.StateFailed
+		podResult.Summary = k.smokeTestFailure(ctx, "pod", name, readyDuration, err)
+		podResult.Details["error"] = api.NewError(err)
+		if events := k.smokeTestEvents(ctx, "Pod", name); len(events) > 0 {
+			podResult.Details["events"] = events
+		}
+		podResult.Remediation = "See the events above for the reason the pod did not become ready"
+		return []*api.CheckResult{pvcResult, podResult}
+	}
+
+	podResult.State = api.StatePassed
+	podResult.Summary = fmt.Sprintf("pod %s mounting the volume was ready in %s", name, readyDuration.Round(time.Millisecond))
+	return []*api.CheckResult{pvcResult, podResult}
+}
+
+// smokeTestLoadBalancer waits for the load balancer of the Service to be
+// provisioned, timed from the creation of the Service.
+func (k *KubernetesChecker) smokeTestLoadBalancer(ctx context.Context, checkName, name string, created time.Time) *api.CheckResult {
+	var service *corev1.Service
+	err := wait.PollImmediateUntil(k.smokeTestInterval, func() (bool, error) {
+		current, err := k.client.CoreV1().Services(k.namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		service = current
+		return len(service.Status.LoadBalancer.Ingress) > 0, nil
+	}, ctx.Done())
+	elapsed := time.Since(created)
+
+	result := &api.CheckResult{
+		Name: checkName,
+		Details: map[string]interface{}{
+			"name":     name,
+			"duration": elapsed.Round(time.Millisecond).String(),
+		},
+	}
+
+	if err != nil {
+		result.State = api.StateFailed
+		result.Summary = k.smokeTestFailure(ctx, "LoadBalancer Service", name, elapsed, err)
+		result.Details["error"] = api.NewError(err)
+		if events := k.smokeTestEvents(ctx, "Service", name); len(events) > 0 {
+			result.Details["events"] = events
+		}
+		result.Remediation = "Install a load balancer implementation, such as the cloud provider's controller or MetalLB on bare metal, " +
+			"or expose Coder with a NodePort Service or an Ingress instead"
+		result.DocsURL = "https://kubernetes.io/docs/concepts/services-networking/service/#loadbalancer"
+		return result
+	}
+
+	addresses := make([]string, 0, len(service.Status.LoadBalancer.Ingress))
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			addresses = append(addresses, ingress.Hostname)
+		} else {
+			addresses = append(addresses, ingress.IP)
+		}
+	}
+	result.Details["addresses"] = addresses
+
+	result.State = api.StatePassed
+	result.Summary = fmt.Sprintf("LoadBalancer Service %s was assigned %s in %s",
+		name, strings.Join(addresses, ", "), elapsed.Round(time.Millisecond))
+	return result
+}
+
+// smokeTestFailure returns the summary of a smoke test that failed
+// waiting for a resource.
+func (k *KubernetesChecker) smokeTestFailure(ctx context.Context, kind, name string, elapsed time.Duration, err error) string {
+	if xerrors.Is(err, wait.ErrWaitTimeout) {
+		// The poll was stopped either by the smoke test timeout, or by
+		// the caller cancelling the checks.
+		if xerrors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Sprintf("%s %s was not ready after %s", kind, name, elapsed.Round(time.Second))
+		}
+		return fmt.Sprintf("smoke test cancelled waiting for %s %s", kind, name)
+	}
+	return fmt.Sprintf("failed waiting for %s %s: %s", kind, name, err)
+}
+
+// smokeTestEvents returns the messages of events about the given object,
+// to help explain why it did not become ready.
+func (k *KubernetesChecker) smokeTestEvents(ctx context.Context, kind, name string) []string {
+	// The smoke test context may have expired, but the events are still
+	// worth fetching.
+	if ctx.Err() != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(context.Background(), smokeTestCleanupTimeout)
+		defer cancel()
+	}
+
+	events, err := k.client.CoreV1().Events(k.namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=%s,involvedObject.name=%s", kind, name),
+	})
+	if err != nil {
+		k.log.Debug(ctx, "unable to list smoke test events", slog.F("name", name), slog.Error(err))
+		return nil
+	}
+
+	messages := make([]string, 0, len(events.Items))
+	for _, event := range events.Items {
+		if event.InvolvedObject.Name != name {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("%s: %s", event.Reason, strings.TrimSpace(event.Message)))
+	}
+	return messages
+}
+
+// cleanupSmokeTest deletes the pods and PersistentVolumeClaims matching
+// the selector. It uses a new context, so that resources are deleted even
+// if the checks were cancelled.
+func (k *KubernetesChecker) cleanupSmokeTest(selector string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), smokeTestCleanupTimeout)
+	defer cancel()
+
+	var errs []string
+	listOpts := metav1.ListOptions{LabelSelector: selector}
+	// Delete pods immediately, since the pause container does nothing on
+	// termination.
+	gracePeriod := int64(0)
+
+	services, err := k.client.CoreV1().Services(k.namespace).List(ctx, listOpts)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("list services: %s", err))
+	} else {
+		// The Service is deleted without waiting for the load balancer to
+		// be released, which the cloud controller tracks with its own
+		// finalizer.
+		for _, service := range services.Items {
+			err := k.client.CoreV1().Services(k.namespace).Delete(ctx, service.Name, metav1.DeleteOptions{})
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("delete service %s: %s", service.Name, err))
+			}
+		}
+	}
+
+	pods, err := k.client.CoreV1().Pods(k.namespace).List(ctx, listOpts)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("list pods: %s", err))
+	} else {
+		for _, pod := range pods.Items {
+			err := k.client.CoreV1().Pods(k.namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &gracePeriod})
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("delete pod %s: %s", pod.Name, err))
+			}
+		}
+	}
+
+	pvcs, err := k.client.CoreV1().PersistentVolumeClaims(k.namespace).List(ctx, listOpts)
+	if err != nil {
+		errs = append(errs, fmt.Sprintf("list persistentvolumeclaims: %s", err))
+	} else {
+		for _, pvc := range pvcs.Items {
+			err := k.client.CoreV1().PersistentVolumeClaims(k.namespace).Delete(ctx, pvc.Name, metav1.DeleteOptions{})
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("delete persistentvolumeclaim %s: %s", pvc.Name, err))
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return xerrors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func smokeTestLabels(runID string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":       "coder-doctor",
+		"app.kubernetes.io/component":  "smoke-test",
+		"app.kubernetes.io/managed-by": "coder-doctor",
+		smokeTestRunLabel:              runID,
+	}
+}
+
+// smokeTestPVC returns a minimal PersistentVolumeClaim using the default
+// StorageClass.
+func smokeTestPVC(name, runID string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: smokeTestLabels(runID),
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: resource.MustParse("1Gi"),
+				},
+			},
+		},
+	}
+}
+
+// smokeTestPod returns a pod that mounts the PersistentVolumeClaim with
+// the same name, and does nothing.
+func smokeTestPod(name, runID string) *corev1.Pod {
+	nonRoot := true
+	user := int64(65535)
+	resources := corev1.ResourceList{
+		corev1.ResourceCPU:    resource.MustParse("10m"),
+		corev1.ResourceMemory: resource.MustParse("16Mi"),
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: smokeTestLabels(runID),
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyNever,
+			SecurityContext: &corev1.PodSecurityContext{
+				RunAsNonRoot: &nonRoot,
+				RunAsUser:    &user,
+			},
+			Containers: []corev1.Container{{
+				Name:  "pause",
+				Image: smokeTestImage,
+				Resources: corev1.ResourceRequirements{
+					Requests: resources,
+					Limits:   resources,
+				},
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "data",
+					MountPath: "/data",
+				}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name: "data",
+				VolumeSource: corev1.VolumeSource{
+					PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+						ClaimName: name,
+					},
+				},
+			}},
+		},
+	}
+}
+
+// smokeTestService returns a LoadBalancer Service with no selector, so
+// that it only exercises provisioning of the load balancer. It has no
+// finalizers, so deleting it never blocks on coder-doctor.
+func smokeTestService(name, runID string) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: smokeTestLabels(runID),
+		},
+		Spec: corev1.ServiceSpec{
+			Type: corev1.ServiceTypeLoadBalancer,
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Protocol:   corev1.ProtocolTCP,
+				Port:       80,
+				TargetPort: intstr.FromInt(80),
+			}},
+		},
+	}
+}
+
+// isPodReady returns true if the pod's Ready condition is true.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == corev1.PodReady {
+			return cond.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,337 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckSmokeTests(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name string
+		// Setup adds reactors to the client.
+		Setup func(*fake.Clientset)
+		// Cancel cancels the context before running the smoke tests.
+		Cancel bool
+		F      func(*testing.T, []*api.CheckResult)
+	}{
+		{
+			Name: "bound and ready",
+			Setup: func(client *fake.Clientset) {
+				bindPVCs(client)
+				readyPods(client)
+				provisionLoadBalancers(client)
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc name", "kubernetes-smoke-pvc", results[0].Name)
+				assert.Equal(t, "pvc should pass", api.StatePassed, results[0].State)
+				assert.True(t, "pvc summary", strings.HasPrefix(results[0].Summary, "PersistentVolumeClaim coder-doctor-smoke-"))
+				assert.Equal(t, "pvc phase", "Bound", results[0].Details["phase"])
+				assert.Equal(t, "pod name", "kubernetes-smoke-pod", results[1].Name)
+				assert.Equal(t, "pod should pass", api.StatePassed, results[1].State)
+				assert.Equal(t, "pod image", smokeTestImage, results[1].Details["image"])
+				assert.Equal(t, "load balancer name", "kubernetes-smoke-loadbalancer", results[2].Name)
+				assert.Equal(t, "load balancer should pass", api.StatePassed, results[2].State)
+				assert.Equal(t, "load balancer addresses", []string{"203.0.113.10", "lb.example.com"}, results[2].Details["addresses"])
+				assert.True(t, "load balancer summary", strings.Contains(results[2].Summary, " was assigned 203.0.113.10, lb.example.com in "))
+			},
+		},
+		{
+			Name: "load balancer pending",
+			Setup: func(client *fake.Clientset) {
+				bindPVCs(client)
+				readyPods(client)
+				var name string
+				client.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					name = action.(k8stesting.CreateAction).GetObject().(*corev1.Service).Name
+					return false, nil, nil
+				})
+				client.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, &corev1.EventList{Items: []corev1.Event{{
+						InvolvedObject: corev1.ObjectReference{Kind: "Service", Name: name},
+						Reason:         "EnsuringLoadBalancer",
+						Message:        "Ensuring load balancer",
+					}}}, nil
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "pod should pass", api.StatePassed, results[1].State)
+				assert.Equal(t, "load balancer should fail", api.StateFailed, results[2].State)
+				assert.True(t, "load balancer summary", strings.Contains(results[2].Summary, " was not ready after "))
+				assert.Equal(t, "events", []string{"EnsuringLoadBalancer: Ensuring load balancer"}, results[2].Details["events"])
+				assert.True(t, "load balancer remediation", strings.Contains(results[2].Remediation, "MetalLB"))
+			},
+		},
+		{
+			Name: "create service error",
+			Setup: func(client *fake.Clientset) {
+				bindPVCs(client)
+				readyPods(client)
+				client.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, xerrors.New("exceeded quota")
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pod should pass", api.StatePassed, results[1].State)
+				assert.Equal(t, "load balancer should fail", api.StateFailed, results[2].State)
+				assert.ErrorContains(t, "error", results[2].Details["error"].(error), "exceeded quota")
+			},
+		},
+		{
+			Name: "pvc not bound",
+			Setup: func(client *fake.Clientset) {
+				var name string
+				client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					name = action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim).Name
+					return false, nil, nil
+				})
+				client.PrependReactor("list", "events", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, &corev1.EventList{Items: []corev1.Event{{
+						InvolvedObject: corev1.ObjectReference{Kind: "PersistentVolumeClaim", Name: name},
+						Reason:         "ProvisioningFailed",
+						Message:        "storageclass.storage.k8s.io \"standard\" not found ",
+					}}}, nil
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should fail", api.StateFailed, results[0].State)
+				assert.True(t, "pvc summary", strings.Contains(results[0].Summary, " was not ready after "))
+				assert.Equal(t, "pvc phase", "", results[0].Details["phase"])
+				assert.Equal(t, "events", []string{`ProvisioningFailed: storageclass.storage.k8s.io "standard" not found`}, results[0].Details["events"])
+				assert.True(t, "pvc remediation", results[0].Remediation != "")
+				assert.Equal(t, "pod should be skipped", api.StateSkipped, results[1].State)
+			},
+		},
+		{
+			Name: "pod failed",
+			Setup: func(client *fake.Clientset) {
+				bindPVCs(client)
+				client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+					pod.Status.Phase = corev1.PodFailed
+					pod.Status.Message = "Pod was rejected"
+					return false, nil, nil
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should pass", api.StatePassed, results[0].State)
+				assert.Equal(t, "pod should fail", api.StateFailed, results[1].State)
+				assert.True(t, "pod summary", strings.HasSuffix(results[1].Summary, ": pod exited with phase Failed: Pod was rejected"))
+				assert.Equal(t, "pod phase", "Failed", results[1].Details["phase"])
+			},
+		},
+		{
+			Name:   "cancelled",
+			Cancel: true,
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should fail", api.StateFailed, results[0].State)
+				assert.True(t, "pvc summary", strings.HasPrefix(results[0].Summary, "smoke test cancelled waiting for PersistentVolumeClaim"))
+			},
+		},
+		{
+			Name: "create pvc error",
+			Setup: func(client *fake.Clientset) {
+				client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, xerrors.New("forbidden")
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should fail", api.StateFailed, results[0].State)
+				assert.ErrorContains(t, "error", results[0].Details["error"].(error), "forbidden")
+				assert.Equal(t, "pod should be skipped", api.StateSkipped, results[1].State)
+			},
+		},
+		{
+			Name: "create pod error",
+			Setup: func(client *fake.Clientset) {
+				client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, xerrors.New("forbidden")
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 3, len(results))
+				assert.Equal(t, "pvc should be skipped", api.StateSkipped, results[0].State)
+				assert.Equal(t, "pod should fail", api.StateFailed, results[1].State)
+				assert.ErrorContains(t, "error", results[1].Details["error"].(error), "forbidden")
+			},
+		},
+		{
+			Name: "cleanup error",
+			Setup: func(client *fake.Clientset) {
+				bindPVCs(client)
+				readyPods(client)
+				provisionLoadBalancers(client)
+				client.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+					return true, nil, xerrors.New("forbidden")
+				})
+			},
+			F: func(t *testing.T, results []*api.CheckResult) {
+				assert.Equal(t, "results", 4, len(results))
+				assert.Equal(t, "pod should pass", api.StatePassed, results[1].State)
+				assert.Equal(t, "cleanup name", "kubernetes-smoke-cleanup", results[3].Name)
+				assert.Equal(t, "cleanup should warn", api.StateWarning, results[3].State)
+				assert.ErrorContains(t, "error", results[3].Details["error"].(error), "forbidden")
+				assert.True(t, "cleanup remediation", strings.HasPrefix(results[3].Remediation,
+					"Delete the remaining resources with: kubectl delete services,pods,pvc --namespace=test --selector=coder.com/doctor-smoke-test="))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset()
+			if test.Setup != nil {
+				test.Setup(client)
+			}
+
+			checker := NewKubernetesChecker(client,
+				WithNamespace("test"),
+				WithSmokeTests(50*time.Millisecond),
+				WithLoadBalancerSmokeTest(50*time.Millisecond),
+			)
+			checker.smokeTestInterval = 5 * time.Millisecond
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if test.Cancel {
+				cancel()
+			}
+
+			test.F(t, checker.CheckSmokeTests(ctx))
+
+			// Resources are deleted regardless of the outcome, unless
+			// deletion itself failed.
+			services, err := client.CoreV1().Services("test").List(context.Background(), metav1.ListOptions{})
+			assert.Success(t, "list services", err)
+			assert.Equal(t, "services remaining", 0, len(services.Items))
+			pvcs, err := client.CoreV1().PersistentVolumeClaims("test").List(context.Background(), metav1.ListOptions{})
+			assert.Success(t, "list pvcs", err)
+			assert.Equal(t, "pvcs remaining", 0, len(pvcs.Items))
+			if test.Name != "cleanup error" {
+				pods, err := client.CoreV1().Pods("test").List(context.Background(), metav1.ListOptions{})
+				assert.Success(t, "list pods", err)
+				assert.Equal(t, "pods remaining", 0, len(pods.Items))
+			}
+		})
+	}
+}
+
+func Test_SmokeTestResources(t *testing.T) {
+	t.Parallel()
+
+	pvc := smokeTestPVC("coder-doctor-smoke-abcde", "abcde")
+	assert.Equal(t, "pvc labels", "abcde", pvc.Labels[smokeTestRunLabel])
+	assert.Equal(t, "pvc storage class", (*string)(nil), pvc.Spec.StorageClassName)
+
+	pod := smokeTestPod("coder-doctor-smoke-abcde", "abcde")
+	assert.Equal(t, "pod labels", "abcde", pod.Labels[smokeTestRunLabel])
+	assert.Equal(t, "pod claim", "coder-doctor-smoke-abcde", pod.Spec.Volumes[0].PersistentVolumeClaim.ClaimName)
+	assert.True(t, "pod runs as non-root", *pod.Spec.SecurityContext.RunAsNonRoot)
+
+	service := smokeTestService("coder-doctor-smoke-abcde", "abcde")
+	assert.Equal(t, "service labels", "abcde", service.Labels[smokeTestRunLabel])
+	assert.Equal(t, "service type", corev1.ServiceTypeLoadBalancer, service.Spec.Type)
+	assert.Equal(t, "service finalizers", 0, len(service.Finalizers))
+}
+
+// bindPVCs marks PersistentVolumeClaims as bound when they are created.
+func bindPVCs(client *fake.Clientset) {
+	client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pvc := action.(k8stesting.CreateAction).GetObject().(*corev1.PersistentVolumeClaim)
+		pvc.Spec.VolumeName = "pvc-" + pvc.Name
+		pvc.Status.Phase = corev1.ClaimBound
+		return false, nil, nil
+	})
+}
+
+// readyPods marks pods as running and ready when they are created.
+func readyPods(client *fake.Clientset) {
+	client.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		pod := action.(k8stesting.CreateAction).GetObject().(*corev1.Pod)
+		pod.Spec.NodeName = "node-a"
+		pod.Status.Phase = corev1.PodRunning
+		pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+		return false, nil, nil
+	})
+}
+
+// provisionLoadBalancers assigns addresses to LoadBalancer Services when
+// they are created.
+func provisionLoadBalancers(client *fake.Clientset) {
+	client.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		service := action.(k8stesting.CreateAction).GetObject().(*corev1.Service)
+		service.Status.LoadBalancer.Ingress = []corev1.LoadBalancerIngress{
+			{IP: "203.0.113.10"},
+			{Hostname: "lb.example.com"},
+		}
+		return false, nil, nil
+	})
+}
+
+func Test_CheckSmokeTests_OptIn(t *testing.T) {
+	t.Parallel()
+
+	t.Run("volume only", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		bindPVCs(client)
+		readyPods(client)
+		client.PrependReactor("create", "services", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			t.Error("LoadBalancer Service should not be created")
+			return true, nil, xerrors.New("unexpected")
+		})
+
+		checker := NewKubernetesChecker(client, WithSmokeTests(50*time.Millisecond))
+		checker.smokeTestInterval = 5 * time.Millisecond
+
+		results := checker.CheckSmokeTests(context.Background())
+		assert.Equal(t, "results", 2, len(results))
+		assert.Equal(t, "pvc should pass", api.StatePassed, results[0].State)
+		assert.Equal(t, "pod should pass", api.StatePassed, results[1].State)
+	})
+
+	t.Run("load balancer only", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		provisionLoadBalancers(client)
+		client.PrependReactor("create", "persistentvolumeclaims", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			t.Error("PersistentVolumeClaim should not be created")
+			return true, nil, xerrors.New("unexpected")
+		})
+
+		checker := NewKubernetesChecker(client, WithLoadBalancerSmokeTest(50*time.Millisecond))
+		checker.smokeTestInterval = 5 * time.Millisecond
+
+		results := checker.CheckSmokeTests(context.Background())
+		assert.Equal(t, "results", 1, len(results))
+		assert.Equal(t, "load balancer name", "kubernetes-smoke-loadbalancer", results[0].Name)
+		assert.Equal(t, "load balancer should pass", api.StatePassed, results[0].State)
+	})
+}
@@ -0,0 +1,193 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"cdr.dev/slog"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+// defaultStorageClassDocsURL documents how to change the default
+// StorageClass.
+const defaultStorageClassDocsURL = "https://kubernetes.io/docs/tasks/administer-cluster/change-default-storage-class/"
+
+// Annotations marking the default StorageClass. The beta annotation is
+// still honored by Kubernetes.
+const (
+	defaultStorageClassAnnotation     = "storageclass.kubernetes.io/is-default-class"
+	defaultStorageClassBetaAnnotation = "storageclass.beta.kubernetes.io/is-default-class"
+)
+
+// Node labels identifying the zone a node runs in.
+const (
+	zoneLabel     = "topology.kubernetes.io/zone"
+	zoneBetaLabel = "failure-domain.beta.kubernetes.io/zone"
+)
+
+// isDefaultStorageClass returns true if the StorageClass is annotated as
+// the default.
+func isDefaultStorageClass(sc *storagev1.StorageClass) bool {
+	return sc.Annotations[defaultStorageClassAnnotation] == "true" ||
+		sc.Annotations[defaultStorageClassBetaAnnotation] == "true"
+}
+
+// CheckStorage checks that the cluster has exactly one default StorageClass,
+// which is used to dynamically provision the PersistentVolumeClaims used by
+// Coder workspaces.
+func (k *KubernetesChecker) CheckStorage(ctx context.Context) *api.CheckResult {
+	const checkName = "kubernetes-storage"
+
+	classes, err := k.client.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if apierrors.IsForbidden(err) {
+		// StorageClasses are cluster-scoped, so namespace-scoped users may
+		// not be able to list them.
+		result := api.SkippedResult(checkName, "unable to check the default StorageClass without permission to list storageclasses", err)
+		result.Remediation = "Run the checks as a user who can list storageclasses, such as a cluster administrator, " +
+			"or check that the cluster has exactly one default StorageClass with: kubectl get storageclasses"
+		result.DocsURL = defaultStorageClassDocsURL
+		return result
+	}
+	if err != nil {
+		return api.ErrorResult(checkName, "failed to list storage classes", err)
+	}
+
+	var defaults []*storagev1.StorageClass
+	names := make([]string, 0, len(classes.Items))
+	for i := range classes.Items {
+		sc := &classes.Items[i]
+		names = append(names, sc.Name)
+		if isDefaultStorageClass(sc) {
+			defaults = append(defaults, sc)
+		}
+	}
+	sort.Strings(names)
+
+	switch len(defaults) {
+	case 1:
+	case 0:
+		result := &api.CheckResult{
+			Name:    checkName,
+			State:   api.StateFailed,
+			Summary: "cluster has no default StorageClass, so workspace volumes cannot be provisioned",
+			Details: map[string]interface{}{
+				"storage-classes": names,
+			},
+			Remediation: fmt.Sprintf("Mark a StorageClass as the default with: kubectl annotate storageclass <name> %s=true",
+				defaultStorageClassAnnotation),
+			DocsURL: defaultStorageClassDocsURL,
+		}
+		if len(names) == 0 {
+			result.Summary = "cluster has no StorageClasses, so workspace volumes cannot be provisioned"
+			result.Remediation = "Install a storage provisioner, and create a default StorageClass for it"
+		}
+		return result
+	default:
+		defaultNames := make([]string, 0, len(defaults))
+		for _, sc := range defaults {
+			defaultNames = append(defaultNames, sc.Name)
+		}
+		sort.Strings(defaultNames)
+
+		return &api.CheckResult{
+			Name:  checkName,
+			State: api.StateFailed,
+			Summary: fmt.Sprintf("cluster has %d default StorageClasses (%s), but must have exactly one",
+				len(defaultNames), strings.Join(defaultNames, ", ")),
+			Details: map[string]interface{}{
+				"storage-classes":         names,
+				"default-storage-classes": defaultNames,
+			},
+			Remediation: fmt.Sprintf("Remove the default annotation from all but one StorageClass with: kubectl annotate storageclass <name> %s-",
+				defaultStorageClassAnnotation),
+			DocsURL: defaultStorageClassDocsURL,
+		}
+	}
+
+	sc := defaults[0]
+
+	// Both of these have defaults applied by the API server, but may be
+	// unset on objects that were not created through it.
+	bindingMode := storagev1.VolumeBindingImmediate
+	if sc.VolumeBindingMode != nil {
+		bindingMode = *sc.VolumeBindingMode
+	}
+	reclaimPolicy := corev1.PersistentVolumeReclaimDelete
+	if sc.ReclaimPolicy != nil {
+		reclaimPolicy = *sc.ReclaimPolicy
+	}
+	expansion := sc.AllowVolumeExpansion != nil && *sc.AllowVolumeExpansion
+
+	result := &api.CheckResult{
+		Name:  checkName,
+		State: api.StatePassed,
+		Summary: fmt.Sprintf("default StorageClass %q uses provisioner %s with %s volume binding",
+			sc.Name, sc.Provisioner, bindingMode),
+		Details: map[string]interface{}{
+			"storage-classes":        names,
+			"default-storage-class":  sc.Name,
+			"provisioner":            sc.Provisioner,
+			"volume-binding-mode":    string(bindingMode),
+			"reclaim-policy":         string(reclaimPolicy),
+			"allow-volume-expansion": expansion,
+		},
+	}
+
+	if bindingMode != storagev1.VolumeBindingImmediate {
+		return result
+	}
+
+	// Volumes bound immediately are provisioned before the pod using them
+	// is scheduled, so in a cluster spanning zones they may be created in
+	// a zone the pod cannot run in.
+	zones, err := k.nodeZones(ctx)
+	if err != nil {
+		k.log.Debug(ctx, "unable to determine node zones", slog.Error(err))
+		return result
+	}
+	result.Details["zones"] = zones
+
+	if len(zones) > 1 {
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("default StorageClass %q uses %s volume binding, but nodes span %d zones (%s)",
+			sc.Name, bindingMode, len(zones), strings.Join(zones, ", "))
+		result.Remediation = fmt.Sprintf("Use a StorageClass with volumeBindingMode: %s, so that volumes are provisioned "+
+			"in the zone the workspace is scheduled in", storagev1.VolumeBindingWaitForFirstConsumer)
+		result.DocsURL = "https://kubernetes.io/docs/concepts/storage/storage-classes/#volume-binding-mode"
+	}
+
+	return result
+}
+
+// nodeZones returns the sorted, distinct zones of the cluster's nodes.
+func (k *KubernetesChecker) nodeZones(ctx context.Context) ([]string, error) {
+	nodes, err := k.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	zones := make([]string, 0)
+	for _, node := range nodes.Items {
+		zone := node.Labels[zoneLabel]
+		if zone == "" {
+			zone = node.Labels[zoneBetaLabel]
+		}
+		if zone == "" || seen[zone] {
+			continue
+		}
+		seen[zone] = true
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	return zones, nil
+}
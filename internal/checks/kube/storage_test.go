@@ -0,0 +1,207 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/xerrors"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+func Test_CheckStorage(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Objects []runtime.Object
+		F       func(*testing.T, *api.CheckResult)
+	}{
+		{
+			Name:    "no storage classes",
+			Objects: nil,
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "cluster has no StorageClasses, so workspace volumes cannot be provisioned", result.Summary)
+			},
+		},
+		{
+			Name:    "no default storage class",
+			Objects: []runtime.Object{storageClass("standard", "kubernetes.io/gce-pd", false, nil)},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "cluster has no default StorageClass, so workspace volumes cannot be provisioned", result.Summary)
+				assert.Equal(t, "storage classes", []string{"standard"}, result.Details["storage-classes"])
+				assert.Equal(t, "docs", defaultStorageClassDocsURL, result.DocsURL)
+			},
+		},
+		{
+			Name: "multiple default storage classes",
+			Objects: []runtime.Object{
+				storageClass("standard", "kubernetes.io/gce-pd", true, nil),
+				storageClass("premium", "pd.csi.storage.gke.io", true, nil),
+				storageClass("slow", "pd.csi.storage.gke.io", false, nil),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should fail", api.StateFailed, result.State)
+				assert.Equal(t, "summary", "cluster has 2 default StorageClasses (premium, standard), but must have exactly one", result.Summary)
+				assert.Equal(t, "default storage classes", []string{"premium", "standard"}, result.Details["default-storage-classes"])
+			},
+		},
+		{
+			Name: "one default storage class",
+			Objects: []runtime.Object{
+				storageClass("standard", "kubernetes.io/gce-pd", false, nil),
+				storageClass("premium", "pd.csi.storage.gke.io", true, bindingMode(storagev1.VolumeBindingWaitForFirstConsumer)),
+				node("node-a", "us-central1-a"),
+				node("node-b", "us-central1-b"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "summary", `default StorageClass "premium" uses provisioner pd.csi.storage.gke.io with WaitForFirstConsumer volume binding`, result.Summary)
+				assert.Equal(t, "default", "premium", result.Details["default-storage-class"])
+				assert.Equal(t, "provisioner", "pd.csi.storage.gke.io", result.Details["provisioner"])
+				assert.Equal(t, "binding mode", "WaitForFirstConsumer", result.Details["volume-binding-mode"])
+				assert.Equal(t, "reclaim policy", "Delete", result.Details["reclaim-policy"])
+				assert.Equal(t, "expansion", true, result.Details["allow-volume-expansion"])
+			},
+		},
+		{
+			Name: "beta default annotation",
+			Objects: []runtime.Object{
+				&storagev1.StorageClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:        "gp2",
+						Annotations: map[string]string{defaultStorageClassBetaAnnotation: "true"},
+					},
+					Provisioner: "kubernetes.io/aws-ebs",
+				},
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "default", "gp2", result.Details["default-storage-class"])
+				assert.Equal(t, "binding mode", "Immediate", result.Details["volume-binding-mode"])
+				assert.Equal(t, "expansion", false, result.Details["allow-volume-expansion"])
+			},
+		},
+		{
+			Name: "immediate binding in one zone",
+			Objects: []runtime.Object{
+				storageClass("standard", "kubernetes.io/gce-pd", true, bindingMode(storagev1.VolumeBindingImmediate)),
+				node("node-a", "us-central1-a"),
+				node("node-b", "us-central1-a"),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should pass", api.StatePassed, result.State)
+				assert.Equal(t, "zones", []string{"us-central1-a"}, result.Details["zones"])
+			},
+		},
+		{
+			Name: "immediate binding across zones",
+			Objects: []runtime.Object{
+				storageClass("standard", "kubernetes.io/gce-pd", true, bindingMode(storagev1.VolumeBindingImmediate)),
+				node("node-a", "us-central1-b"),
+				node("node-b", "us-central1-a"),
+				node("node-c", ""),
+			},
+			F: func(t *testing.T, result *api.CheckResult) {
+				assert.Equal(t, "should warn", api.StateWarning, result.State)
+				assert.Equal(t, "summary", `default StorageClass "standard" uses Immediate volume binding, but nodes span 2 zones (us-central1-a, us-central1-b)`, result.Summary)
+				assert.True(t, "should have a remediation", result.Remediation != "")
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			client := fake.NewSimpleClientset(test.Objects...)
+			checker := NewKubernetesChecker(client)
+			test.F(t, checker.CheckStorage(context.Background()))
+		})
+	}
+}
+
+func Test_CheckStorage_Errors(t *testing.T) {
+	t.Parallel()
+
+	t.Run("list storage classes", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "storageclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("ouch")
+		})
+
+		result := NewKubernetesChecker(client).CheckStorage(context.Background())
+		assert.Equal(t, "should fail", api.StateFailed, result.State)
+		assert.ErrorContains(t, "error", result.Details["error"].(error), "ouch")
+	})
+
+	t.Run("forbidden", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset()
+		client.Fake.PrependReactor("list", "storageclasses", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, apierrors.NewForbidden(schema.GroupResource{Group: "storage.k8s.io", Resource: "storageclasses"}, "", xerrors.New("RBAC denied"))
+		})
+
+		result := NewKubernetesChecker(client).CheckStorage(context.Background())
+		assert.Equal(t, "should be skipped", api.StateSkipped, result.State)
+		assert.Equal(t, "summary", "unable to check the default StorageClass without permission to list storageclasses", result.Summary)
+		assert.True(t, "remediation", result.Remediation != "")
+	})
+
+	t.Run("list nodes", func(t *testing.T) {
+		t.Parallel()
+
+		client := fake.NewSimpleClientset(storageClass("standard", "kubernetes.io/gce-pd", true, nil))
+		client.Fake.PrependReactor("list", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+			return true, nil, xerrors.New("forbidden")
+		})
+
+		result := NewKubernetesChecker(client).CheckStorage(context.Background())
+		assert.Equal(t, "should pass without zones", api.StatePassed, result.State)
+		assert.Equal(t, "zones", nil, result.Details["zones"])
+	})
+}
+
+func storageClass(name, provisioner string, isDefault bool, mode *storagev1.VolumeBindingMode) *storagev1.StorageClass {
+	reclaim := corev1.PersistentVolumeReclaimDelete
+	expansion := mode != nil
+	sc := &storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: name},
+		Provisioner:          provisioner,
+		VolumeBindingMode:    mode,
+		ReclaimPolicy:        &reclaim,
+		AllowVolumeExpansion: &expansion,
+	}
+	if isDefault {
+		sc.Annotations = map[string]string{defaultStorageClassAnnotation: "true"}
+	}
+	return sc
+}
+
+func bindingMode(mode storagev1.VolumeBindingMode) *storagev1.VolumeBindingMode {
+	return &mode
+}
+
+func node(name, zone string) *corev1.Node {
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}}
+	if zone != "" {
+		n.Labels = map[string]string{zoneLabel: zone}
+	}
+	return n
+}
@@ -9,6 +9,10 @@ import (
 	"cdr.dev/slog/sloggers/slogtest/assert"
 )
 
+var verbsAll ResourceVerbs = []string{"create", "delete", "deletecollection", "get", "list", "update", "patch", "watch"}
+var verbsGetListWatch = []string{"get", "list", "watch"}
+var verbsGetCreate = []string{"get", "create"}
+
 // newTestHTTPServer creates a HTTP server that just returns the given status code and response.
 func newTestHTTPServer(t *testing.T, statusCode int, resp interface{}) *httptest.Server {
 	t.Helper()
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"k8s.io/apimachinery/pkg/version"
@@ -11,31 +12,49 @@ import (
 	"cdr.dev/slog"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 )
 
+// kubernetesVersionDocsURL documents the Kubernetes versions supported by
+// each version of Coder.
+const kubernetesVersionDocsURL = "https://coder.com/docs/coder/latest/setup/kubernetes#supported-kubernetes-versions"
+
+// CoderVersionRequirement describes the Kubernetes versions supported by a
+// version of Coder. Versions matching KubernetesTested pass. Versions
+// matching KubernetesSupported (untested) or KubernetesDeprecated produce
+// a warning, and any other version fails. The optional tiers may be nil.
 type CoderVersionRequirement struct {
 	CoderVersion         *semver.Version
-	KubernetesVersionMin *semver.Version
-	KubernetesVersionMax *semver.Version
+	KubernetesTested     *semver.Constraints
+	KubernetesSupported  *semver.Constraints
+	KubernetesDeprecated *semver.Constraints
 }
 
-var versionRequirements = []CoderVersionRequirement{
-	{
-		CoderVersion:         semver.MustParse("1.21"),
-		KubernetesVersionMin: semver.MustParse("1.19"),
-		KubernetesVersionMax: semver.MustParse("1.22"),
-	},
-	{
-		CoderVersion:         semver.MustParse("1.20"),
-		KubernetesVersionMin: semver.MustParse("1.19"),
-		KubernetesVersionMax: semver.MustParse("1.21"),
-	},
+// versionRequirementsFromManifest returns the Kubernetes versions supported
+// by each version of Coder listed in the manifest, ordered by version
+// descending.
+func versionRequirementsFromManifest(m *requirements.Manifest) []CoderVersionRequirement {
+	reqs := make([]CoderVersionRequirement, 0, len(m.Coder))
+	for _, c := range m.Coder {
+		req := CoderVersionRequirement{
+			CoderVersion:     c.Version.Version,
+			KubernetesTested: c.Kubernetes.Tested.Constraints,
+		}
+		if c.Kubernetes.Supported != nil {
+			req.KubernetesSupported = c.Kubernetes.Supported.Constraints
+		}
+		if c.Kubernetes.Deprecated != nil {
+			req.KubernetesDeprecated = c.Kubernetes.Deprecated.Constraints
+		}
+		reqs = append(reqs, req)
+	}
+	return reqs
 }
 
-func findNearestVersion(coderVersion *semver.Version) *CoderVersionRequirement {
+func findNearestVersion(reqs []CoderVersionRequirement, coderVersion *semver.Version) *CoderVersionRequirement {
 	var selectedVersion *CoderVersionRequirement
 
-	for _, v := range versionRequirements {
+	for _, v := range reqs {
 		v := v
 		if !v.CoderVersion.GreaterThan(coderVersion) {
 			selectedVersion = &v
@@ -63,23 +82,26 @@ func (k *KubernetesChecker) CheckVersion(ctx context.Context) *api.CheckResult {
 		return api.ErrorResult(checkName, "failed to unmarshal version info", err)
 	}
 
-	selectedVersion := findNearestVersion(k.coderVersion)
+	selectedVersion := findNearestVersion(k.versionReqs, k.coderVersion)
 	k.log.Debug(ctx, "selected coder version",
 		slog.F("requested", k.coderVersion),
 		slog.F("selected", selectedVersion.CoderVersion))
 
-	kubernetesVersion, err := semver.NewVersion(versionInfo.GitVersion)
+	serverVersion, err := semver.NewVersion(versionInfo.GitVersion)
 	if err != nil {
 		return api.ErrorResult(checkName, "failed to parse server version", err)
 	}
+	kubernetesVersion, vendorSuffix := normalizeKubernetesVersion(serverVersion)
 
 	result := &api.CheckResult{
-		Name: checkName,
+		Name:    checkName,
+		DocsURL: kubernetesVersionDocsURL,
 		Details: map[string]interface{}{
 			"coder-version":       selectedVersion.CoderVersion.String(),
 			"coder-version-major": selectedVersion.CoderVersion.Major(),
 			"coder-version-minor": selectedVersion.CoderVersion.Minor(),
 			"coder-version-patch": selectedVersion.CoderVersion.Patch(),
+			"kubernetes-tested":   selectedVersion.KubernetesTested.String(),
 			"platform":            versionInfo.Platform,
 			"major":               versionInfo.Major,
 			"minor":               versionInfo.Minor,
@@ -91,16 +113,70 @@ func (k *KubernetesChecker) CheckVersion(ctx context.Context) *api.CheckResult {
 			"compiler":            versionInfo.Compiler,
 		},
 	}
+	if selectedVersion.KubernetesSupported != nil {
+		result.Details["kubernetes-supported"] = selectedVersion.KubernetesSupported.String()
+	}
+	if selectedVersion.KubernetesDeprecated != nil {
+		result.Details["kubernetes-deprecated"] = selectedVersion.KubernetesDeprecated.String()
+	}
+	if vendorSuffix != "" {
+		result.Details["vendor-suffix"] = vendorSuffix
+	}
 
-	if kubernetesVersion.LessThan(selectedVersion.KubernetesVersionMin) || kubernetesVersion.GreaterThan(selectedVersion.KubernetesVersionMax) {
-		result.State = api.StateFailed
-		result.Summary = fmt.Sprintf("Coder %s supports Kubernetes %s to %s and was not tested with %s",
-			k.coderVersion, selectedVersion.KubernetesVersionMin, selectedVersion.KubernetesVersionMax, kubernetesVersion)
-	} else {
+	switch {
+	case selectedVersion.KubernetesDeprecated != nil && selectedVersion.KubernetesDeprecated.Check(kubernetesVersion):
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("Coder %s support for Kubernetes %s is deprecated (server version %s)",
+			k.coderVersion, kubernetesVersion, serverVersion)
+		result.Remediation = fmt.Sprintf("Upgrade the cluster to a Kubernetes version matching %s", selectedVersion.KubernetesTested)
+	case selectedVersion.KubernetesTested.Check(kubernetesVersion):
 		result.State = api.StatePassed
-		result.Summary = fmt.Sprintf("Coder %s supports Kubernetes %s to %s (server version %s)",
-			k.coderVersion, selectedVersion.KubernetesVersionMin, selectedVersion.KubernetesVersionMax, kubernetesVersion)
+		result.Summary = fmt.Sprintf("Coder %s supports Kubernetes %s (server version %s)",
+			k.coderVersion, selectedVersion.KubernetesTested, serverVersion)
+	case selectedVersion.KubernetesSupported != nil && selectedVersion.KubernetesSupported.Check(kubernetesVersion):
+		result.State = api.StateWarning
+		result.Summary = fmt.Sprintf("Coder %s supports Kubernetes %s but was not tested with %s",
+			k.coderVersion, selectedVersion.KubernetesSupported, serverVersion)
+		result.Remediation = fmt.Sprintf("Use a Kubernetes version matching %s for a tested configuration", selectedVersion.KubernetesTested)
+	default:
+		result.State = api.StateFailed
+		result.Summary = fmt.Sprintf("Coder %s supports Kubernetes %s and was not tested with %s",
+			k.coderVersion, selectedVersion.KubernetesTested, serverVersion)
+		result.Remediation = fmt.Sprintf("Upgrade the cluster to a Kubernetes version matching %s, "+
+			"or select a compatible version of Coder with --coder-version",
+			selectedVersion.KubernetesTested)
 	}
 
 	return result
 }
+
+// prereleaseIdentifiers are the prefixes of pre-release identifiers used
+// by Kubernetes itself. Any other pre-release identifier (such as "gke.900"
+// or "eks-0389ca3") is a vendor suffix.
+var prereleaseIdentifiers = []string{"alpha", "beta", "rc"}
+
+// normalizeKubernetesVersion strips vendor suffixes, such as
+// "v1.21.5-gke.1302" or "v1.21.5+k3s1", from a Kubernetes server version
+// so it can be matched against version constraints, which would otherwise
+// treat it as a pre-release. Upstream pre-releases such as "v1.23.0-rc.1"
+// are left unchanged. The stripped suffix is also returned.
+func normalizeKubernetesVersion(v *semver.Version) (*semver.Version, string) {
+	for _, prefix := range prereleaseIdentifiers {
+		if strings.HasPrefix(v.Prerelease(), prefix) {
+			return v, ""
+		}
+	}
+
+	var suffix string
+	if v.Prerelease() != "" {
+		suffix = "-" + v.Prerelease()
+	}
+	if v.Metadata() != "" {
+		suffix += "+" + v.Metadata()
+	}
+	if suffix == "" {
+		return v, ""
+	}
+
+	return semver.MustParse(fmt.Sprintf("%d.%d.%d", v.Major(), v.Minor(), v.Patch())), suffix
+}
@@ -11,6 +11,7 @@ import (
 	"k8s.io/client-go/rest"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 	"cdr.dev/slog/sloggers/slogtest/assert"
 )
 
@@ -40,21 +41,25 @@ func TestVersions(t *testing.T) {
 			ExpectedResult: &api.CheckResult{
 				Name:    "kubernetes-version",
 				State:   api.StatePassed,
-				Summary: "Coder 1.21.0 supports Kubernetes 1.19.0 to 1.22.0 (server version 1.20.8-gke.900)",
+				Summary: "Coder 1.21.0 supports Kubernetes >=1.19 <1.23 (server version 1.20.8-gke.900)",
+				DocsURL: kubernetesVersionDocsURL,
 				Details: map[string]interface{}{
-					"coder-version":       "1.21.0",
-					"coder-version-major": uint64(1),
-					"coder-version-minor": uint64(21),
-					"coder-version-patch": uint64(0),
-					"build-date":          "2021-06-30T09:23:36Z",
-					"compiler":            "gc",
-					"git-commit":          "28ab8501be88ea42e897ca8514d7cd0b436253d9",
-					"git-tree-state":      "clean",
-					"git-version":         "v1.20.8-gke.900",
-					"go-version":          "go1.15.13b5",
-					"major":               "1",
-					"minor":               "20+",
-					"platform":            "linux/amd64",
+					"coder-version":        "1.21.0",
+					"coder-version-major":  uint64(1),
+					"coder-version-minor":  uint64(21),
+					"coder-version-patch":  uint64(0),
+					"build-date":           "2021-06-30T09:23:36Z",
+					"compiler":             "gc",
+					"git-commit":           "28ab8501be88ea42e897ca8514d7cd0b436253d9",
+					"git-tree-state":       "clean",
+					"git-version":          "v1.20.8-gke.900",
+					"kubernetes-tested":    ">=1.19 <1.23",
+					"kubernetes-supported": "~1.23",
+					"vendor-suffix":        "-gke.900",
+					"go-version":           "go1.15.13b5",
+					"major":                "1",
+					"minor":                "20+",
+					"platform":             "linux/amd64",
 				},
 			},
 		},
@@ -75,21 +80,27 @@ func TestVersions(t *testing.T) {
 			ExpectedResult: &api.CheckResult{
 				Name:    "kubernetes-version",
 				State:   api.StateFailed,
-				Summary: "Coder 1.21.0 supports Kubernetes 1.19.0 to 1.22.0 and was not tested with 1.18.20-gke.900",
+				Summary: "Coder 1.21.0 supports Kubernetes >=1.19 <1.23 and was not tested with 1.18.20-gke.900",
+				Remediation: "Upgrade the cluster to a Kubernetes version matching >=1.19 <1.23, " +
+					"or select a compatible version of Coder with --coder-version",
+				DocsURL: kubernetesVersionDocsURL,
 				Details: map[string]interface{}{
-					"coder-version":       "1.21.0",
-					"coder-version-major": uint64(1),
-					"coder-version-minor": uint64(21),
-					"coder-version-patch": uint64(0),
-					"build-date":          "2021-06-28T09:19:58Z",
-					"compiler":            "gc",
-					"git-commit":          "1facb91642e16cb4f5be4e4a632c488aa4700382",
-					"git-tree-state":      "clean",
-					"git-version":         "v1.18.20-gke.900",
-					"go-version":          "go1.13.15b4",
-					"major":               "1",
-					"minor":               "18+",
-					"platform":            "linux/amd64",
+					"coder-version":        "1.21.0",
+					"coder-version-major":  uint64(1),
+					"coder-version-minor":  uint64(21),
+					"coder-version-patch":  uint64(0),
+					"build-date":           "2021-06-28T09:19:58Z",
+					"compiler":             "gc",
+					"git-commit":           "1facb91642e16cb4f5be4e4a632c488aa4700382",
+					"git-tree-state":       "clean",
+					"git-version":          "v1.18.20-gke.900",
+					"kubernetes-tested":    ">=1.19 <1.23",
+					"kubernetes-supported": "~1.23",
+					"vendor-suffix":        "-gke.900",
+					"go-version":           "go1.13.15b4",
+					"major":                "1",
+					"minor":                "18+",
+					"platform":             "linux/amd64",
 				},
 			},
 		},
@@ -177,8 +188,94 @@ func TestNearestVersion(t *testing.T) {
 			requestedVersion := semver.MustParse(test.RequestedVersion)
 			nearestVersion := semver.MustParse(test.NearestVersion)
 
-			found := findNearestVersion(requestedVersion)
+			found := findNearestVersion(versionRequirementsFromManifest(requirements.Default()), requestedVersion)
 			assert.Equal(t, "nearest version matches", nearestVersion, found.CoderVersion)
 		})
 	}
 }
+
+func TestVersionWithRequirements(t *testing.T) {
+	t.Parallel()
+
+	manifest, err := requirements.Parse([]byte(`
+schemaVersion: 1
+coder:
+  - version: "1.21.0"
+    kubernetes: { tested: ">= 1.21, < 1.24" }
+    helm: ">= 3.6.0"
+resources:
+  - constraint: ">= 1.21"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: [get] }
+`))
+	assert.Success(t, "parse requirements", err)
+
+	srv := newTestHTTPServer(t, http.StatusOK, &version.Info{GitVersion: "v1.20.8"})
+	defer srv.Close()
+
+	client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+	assert.Success(t, "failed to create client", err)
+
+	checker := NewKubernetesChecker(client, WithRequirements(manifest), WithCoderVersion(semver.MustParse("1.21.4")))
+	assert.Equal(t, "resource requirements from manifest", 1, len(checker.reqs.ResourceRequirements))
+
+	result := checker.CheckVersion(context.Background())
+	assert.Equal(t, "failed check", api.StateFailed, result.State)
+	assert.Equal(t, "summary uses manifest constraint", "Coder 1.21.4 supports Kubernetes >=1.21 <1.24 and was not tested with 1.20.8", result.Summary)
+}
+
+func TestVersionTiers(t *testing.T) {
+	t.Parallel()
+
+	manifest, err := requirements.Parse([]byte(`
+schemaVersion: 1
+coder:
+  - version: "1.21.0"
+    kubernetes: { tested: ">= 1.19, < 1.23", supported: "~1.23", deprecated: "~1.19" }
+    helm: ">= 3.6.0"
+resources:
+  - constraint: ">= 1.21"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: [get] }
+`))
+	assert.Success(t, "parse requirements", err)
+
+	tests := []struct {
+		GitVersion   string
+		State        api.CheckState
+		VendorSuffix interface{}
+	}{
+		{GitVersion: "v1.22.5", State: api.StatePassed},
+		{GitVersion: "v1.20.0", State: api.StatePassed},
+		{GitVersion: "v1.21.5-gke.1302", State: api.StatePassed, VendorSuffix: "-gke.1302"},
+		{GitVersion: "v1.21.2-eks-0389ca3", State: api.StatePassed, VendorSuffix: "-eks-0389ca3"},
+		{GitVersion: "v1.22.4+k3s1", State: api.StatePassed, VendorSuffix: "+k3s1"},
+		{GitVersion: "v1.23.1+rke2r2", State: api.StateWarning, VendorSuffix: "+rke2r2"},
+		{GitVersion: "v1.19.16", State: api.StateWarning},
+		{GitVersion: "v1.18.20-gke.900", State: api.StateFailed, VendorSuffix: "-gke.900"},
+		{GitVersion: "v1.24.0", State: api.StateFailed},
+		// Upstream pre-releases are not treated as vendor suffixes.
+		{GitVersion: "v1.22.0-rc.1", State: api.StateFailed},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.GitVersion, func(t *testing.T) {
+			t.Parallel()
+
+			srv := newTestHTTPServer(t, http.StatusOK, &version.Info{GitVersion: test.GitVersion})
+			defer srv.Close()
+
+			client, err := kubernetes.NewForConfig(&rest.Config{Host: srv.URL})
+			assert.Success(t, "failed to create client", err)
+
+			checker := NewKubernetesChecker(client, WithRequirements(manifest), WithCoderVersion(semver.MustParse("1.21.0")))
+			result := checker.CheckVersion(context.Background())
+			assert.Equal(t, "state", test.State, result.State)
+			assert.Equal(t, "vendor suffix", test.VendorSuffix, result.Details["vendor-suffix"])
+			if test.State != api.StatePassed {
+				assert.True(t, "should have a remediation", result.Remediation != "")
+			}
+		})
+	}
+}
@@ -9,25 +9,32 @@ import (
 	"github.com/Masterminds/semver/v3"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 	"cdr.dev/slog"
 )
 
 const LocalHelmVersionCheck = "local-helm-version"
 
+// helmInstallDocsURL documents how to install Helm.
+const helmInstallDocsURL = "https://helm.sh/docs/intro/install/"
+
 type VersionRequirement struct {
 	Coder          *semver.Version
 	HelmConstraint *semver.Constraints
 }
 
-var versionRequirements = []VersionRequirement{
-	{
-		Coder:          semver.MustParse("1.21.0"),
-		HelmConstraint: api.MustConstraint(">= 3.6.0"),
-	},
-	{
-		Coder:          semver.MustParse("1.20.0"),
-		HelmConstraint: api.MustConstraint(">= 3.6.0"),
-	},
+// versionRequirementsFromManifest returns the Helm versions supported by
+// each version of Coder listed in the manifest, ordered by version
+// descending.
+func versionRequirementsFromManifest(m *requirements.Manifest) []VersionRequirement {
+	reqs := make([]VersionRequirement, 0, len(m.Coder))
+	for _, c := range m.Coder {
+		reqs = append(reqs, VersionRequirement{
+			Coder:          c.Version.Version,
+			HelmConstraint: c.Helm.Constraints,
+		})
+	}
+	return reqs
 }
 
 func (l *Checker) CheckLocalHelmVersion(ctx context.Context) *api.CheckResult {
@@ -37,7 +44,10 @@ func (l *Checker) CheckLocalHelmVersion(ctx context.Context) *api.CheckResult {
 
 	helmBin, err := l.lookPathF("helm")
 	if err != nil {
-		return api.ErrorResult(LocalHelmVersionCheck, "could not find helm binary in $PATH", err)
+		result := api.ErrorResult(LocalHelmVersionCheck, "could not find helm binary in $PATH", err)
+		result.Remediation = "Install Helm, and ensure the helm binary is in your $PATH"
+		result.DocsURL = helmInstallDocsURL
+		return result
 	}
 
 	helmVersionRaw, err := l.execF(ctx, helmBin, "version", "--short")
@@ -50,7 +60,7 @@ func (l *Checker) CheckLocalHelmVersion(ctx context.Context) *api.CheckResult {
 		return api.ErrorResult(LocalHelmVersionCheck, "failed to parse helm version", err)
 	}
 
-	selectedVersion := findNearestHelmVersion(l.coderVersion)
+	selectedVersion := findNearestHelmVersion(l.versionReqs, l.coderVersion)
 	l.log.Debug(ctx, "selected coder version", slog.F("requested", l.coderVersion), slog.F("selected", selectedVersion.Coder))
 
 	result := &api.CheckResult{
@@ -75,6 +85,8 @@ func (l *Checker) CheckLocalHelmVersion(ctx context.Context) *api.CheckResult {
 			}
 		}
 		result.Summary = b.String()
+		result.Remediation = fmt.Sprintf("Install a version of Helm matching %s", selectedVersion.HelmConstraint)
+		result.DocsURL = helmInstallDocsURL
 	} else {
 		result.State = api.StatePassed
 		result.Summary = fmt.Sprintf("Coder %s supports Helm %s", selectedVersion.Coder, selectedVersion.HelmConstraint)
@@ -83,10 +95,10 @@ func (l *Checker) CheckLocalHelmVersion(ctx context.Context) *api.CheckResult {
 	return result
 }
 
-func findNearestHelmVersion(target *semver.Version) *VersionRequirement {
+func findNearestHelmVersion(reqs []VersionRequirement, target *semver.Version) *VersionRequirement {
 	var selected *VersionRequirement
 
-	for _, v := range versionRequirements {
+	for _, v := range reqs {
 		v := v
 		if !v.Coder.GreaterThan(target) {
 			selected = &v
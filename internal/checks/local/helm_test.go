@@ -8,6 +8,7 @@ import (
 	"github.com/Masterminds/semver/v3"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 	"cdr.dev/slog/sloggers/slogtest/assert"
 )
 
@@ -82,6 +83,35 @@ func Test_CheckLocalHelmVersion(t *testing.T) {
 		for _, res := range p.W.Get() {
 			if res.Name == LocalHelmVersionCheck {
 				assert.Equal(t, "should fail", api.StateFailed, res.State)
+				assert.Equal(t, "should suggest helm version", "Install a version of Helm matching >=3.6.0", res.Remediation)
+				assert.Equal(t, "should link to helm docs", helmInstallDocsURL, res.DocsURL)
+			}
+		}
+	})
+
+	run(t, "helm: with requirements file", func(t *testing.T, p *params) {
+		manifest, err := requirements.Parse([]byte(`
+schemaVersion: 1
+coder:
+  - version: "1.21.0"
+    kubernetes: { tested: ">= 1.19, < 1.23" }
+    helm: ">= 3.7.0"
+resources:
+  - constraint: ">= 1.21"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: [get] }
+`))
+		assert.Success(t, "parse requirements", err)
+		p.Opts = append(p.Opts, WithRequirements(manifest))
+		p.LP.Handle("helm", "/usr/local/bin/helm", nil)
+		p.EX.Handle("/usr/local/bin/helm version --short", []byte("v3.6.0+g7f2df64"), nil)
+		lc := NewChecker(p.Opts...)
+		err = lc.Run(p.Ctx)
+		assert.Success(t, "run local checker", err)
+		for _, res := range p.W.Get() {
+			if res.Name == LocalHelmVersionCheck {
+				assert.Equal(t, "should fail", api.StateFailed, res.State)
+				assert.Equal(t, "should suggest helm version", "Install a version of Helm matching >=3.7.0", res.Remediation)
 			}
 		}
 	})
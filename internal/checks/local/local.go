@@ -12,6 +12,7 @@ import (
 	"cdr.dev/slog/sloggers/sloghuman"
 
 	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/requirements"
 )
 
 var _ api.Checker = &Checker{}
@@ -27,6 +28,8 @@ type Checker struct {
 	target       api.CheckTarget
 	execF        ExecF
 	lookPathF    LookPathF
+	requirements *requirements.Manifest
+	versionReqs  []VersionRequirement
 }
 
 type Option func(*Checker)
@@ -38,12 +41,15 @@ func NewChecker(opts ...Option) *Checker {
 		log:          slog.Make(sloghuman.Sink(io.Discard)),
 		execF:        defaultExecCommand,
 		lookPathF:    exec.LookPath,
+		requirements: requirements.Default(),
 	}
 
 	for _, opt := range opts {
 		opt(checker)
 	}
 
+	checker.versionReqs = versionRequirementsFromManifest(checker.requirements)
+
 	if err := checker.Validate(); err != nil {
 		panic(xerrors.Errorf("error validating local checker: %w", err))
 	}
@@ -75,6 +81,14 @@ func WithLogger(log slog.Logger) Option {
 	}
 }
 
+// WithRequirements sets the requirements checked against, in place of
+// the defaults embedded in the binary.
+func WithRequirements(m *requirements.Manifest) Option {
+	return func(l *Checker) {
+		l.requirements = m
+	}
+}
+
 func WithExecF(f ExecF) Option {
 	return func(l *Checker) {
 		l.execF = f
@@ -89,7 +103,7 @@ func WithLookPathF(f LookPathF) Option {
 
 func (l *Checker) Validate() error {
 	// Ensure we know the Helm version requirement for our Coder version.
-	if findNearestHelmVersion(l.coderVersion) == nil {
+	if findNearestHelmVersion(l.versionReqs, l.coderVersion) == nil {
 		return xerrors.Errorf("unhandled coder version %s: compatible helm version not specified", l.coderVersion.String())
 	}
 	return nil
@@ -15,6 +15,8 @@ func NewCommand() *cobra.Command {
 
 	checkCmd.PersistentFlags().Int("verbosity", 0, "log level verbosity")
 	checkCmd.PersistentFlags().String("coder-version", "1.21", "version of Coder")
+	checkCmd.PersistentFlags().String("requirements-file", "", "path to a requirements manifest to use instead of the built-in requirements")
+	checkCmd.PersistentFlags().String("fail-on", "fail", "lowest result state that causes a non-zero exit code (one of: warn, fail)")
 
 	checkCmd.AddCommand(
 		kubernetes.NewCommand(),
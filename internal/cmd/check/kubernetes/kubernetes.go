@@ -3,6 +3,7 @@ package kubernetes
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
@@ -11,6 +12,7 @@ import (
 	"cdr.dev/slog"
 	"cdr.dev/slog/sloggers/sloghuman"
 
+	rbacv1 "k8s.io/api/rbac/v1"
 	kclient "k8s.io/client-go/kubernetes"
 	// Kubernetes authentication plugins
 	_ "k8s.io/client-go/plugin/pkg/client/auth/azure"
@@ -19,11 +21,19 @@ import (
 	_ "k8s.io/client-go/plugin/pkg/client/auth/oidc"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/openstack"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
 
 	"cdr.dev/coder-doctor/internal/api"
 	"cdr.dev/coder-doctor/internal/checks/kube"
 	"cdr.dev/coder-doctor/internal/checks/local"
-	"cdr.dev/coder-doctor/internal/humanwriter"
+	"cdr.dev/coder-doctor/internal/cmd/output"
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+// Client rate limits used unless set in the Kubernetes configuration.
+const (
+	defaultQPS   = 50
+	defaultBurst = 100
 )
 
 func NewCommand() *cobra.Command {
@@ -37,6 +47,17 @@ func NewCommand() *cobra.Command {
 	kubernetesCmd.PersistentFlags().String(clientcmd.FlagContext, "", "the name of the Kubernetes context to use")
 	kubernetesCmd.PersistentFlags().String(clientcmd.RecommendedConfigPathFlag, "", "path to the Kubernetes configuration file")
 	kubernetesCmd.PersistentFlags().StringP(clientcmd.FlagNamespace, "n", "", "the name of the Kubernetes namespace to deploy into")
+	kubernetesCmd.PersistentFlags().String("emit-rbac", "", "write a Role and RoleBinding granting any missing RBAC permissions to this file")
+	kubernetesCmd.PersistentFlags().String("emit-rbac-user", "", "the user to bind the roles written by --emit-rbac to (default: the current user)")
+	kubernetesCmd.PersistentFlags().String("as-serviceaccount", "", "check the RBAC permissions of this service account (namespace/name) instead of the current user")
+	kubernetesCmd.PersistentFlags().String("as", "", "check the RBAC permissions of this user instead of the current user")
+	kubernetesCmd.PersistentFlags().Int("concurrency", kube.DefaultConcurrency, "the maximum number of concurrent requests used when checking RBAC permissions")
+	kubernetesCmd.PersistentFlags().StringArray("as-group", nil, "groups of the user given by --as, can be repeated")
+	kubernetesCmd.PersistentFlags().Int("workspaces", kube.DefaultWorkspaces, "the number of workspaces the namespace's resource quota must fit, in addition to the control plane")
+	kubernetesCmd.PersistentFlags().Bool("smoke-tests", false, "create a PersistentVolumeClaim and pod in the namespace to check that Coder can start")
+	kubernetesCmd.PersistentFlags().Duration("smoke-test-timeout", kube.DefaultSmokeTestTimeout, "the time to wait for resources created by --smoke-tests to become ready")
+	kubernetesCmd.PersistentFlags().Bool("smoke-test-loadbalancer", false, "create a LoadBalancer Service in the namespace to check that a load balancer can be provisioned (may incur cloud provider charges)")
+	kubernetesCmd.PersistentFlags().Duration("smoke-test-loadbalancer-timeout", kube.DefaultLoadBalancerSmokeTestTimeout, "the time to wait for the load balancer created by --smoke-test-loadbalancer")
 
 	return kubernetesCmd
 }
@@ -64,10 +85,64 @@ func getConfigOverridesFromFlags(cmd *cobra.Command) (*clientcmd.ConfigOverrides
 	return overrides, nil
 }
 
-func run(cmd *cobra.Command, _ []string) error {
+// loadRequirements returns the requirements manifest given by
+// --requirements-file, or the built-in requirements if it is not set.
+func loadRequirements(cmd *cobra.Command) (*requirements.Manifest, error) {
+	path, err := cmd.Flags().GetString("requirements-file")
+	if err != nil {
+		return nil, xerrors.Errorf("parse requirements-file: %w", err)
+	}
+
+	if path == "" {
+		return requirements.Default(), nil
+	}
+
+	reqs, err := requirements.Load(path)
+	if err != nil {
+		return nil, xerrors.Errorf("load requirements: %w", err)
+	}
+	return reqs, nil
+}
+
+// getSubjectOptionFromFlags returns the option selecting the subject whose
+// RBAC permissions are checked, or nil to check the current user.
+func getSubjectOptionFromFlags(cmd *cobra.Command) (kube.Option, error) {
+	serviceAccount, err := cmd.Flags().GetString("as-serviceaccount")
+	if err != nil {
+		return nil, xerrors.Errorf("parse as-serviceaccount: %w", err)
+	}
+
+	user, err := cmd.Flags().GetString("as")
+	if err != nil {
+		return nil, xerrors.Errorf("parse as: %w", err)
+	}
+
+	groups, err := cmd.Flags().GetStringArray("as-group")
+	if err != nil {
+		return nil, xerrors.Errorf("parse as-group: %w", err)
+	}
+
+	switch {
+	case serviceAccount != "" && user != "":
+		return nil, xerrors.New("--as-serviceaccount and --as are mutually exclusive")
+	case len(groups) > 0 && user == "":
+		return nil, xerrors.New("--as-group requires --as")
+	case serviceAccount != "":
+		parts := strings.Split(serviceAccount, "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, xerrors.Errorf("parse as-serviceaccount: %q is not of the form namespace/name", serviceAccount)
+		}
+		return kube.WithServiceAccount(parts[0], parts[1]), nil
+	case user != "":
+		return kube.WithSubject(user, groups), nil
+	}
+
+	return nil, nil
+}
+
+func run(cmd *cobra.Command, _ []string) (err error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 
-	var err error
 	loadingRules.ExplicitPath, err = cmd.Flags().GetString(clientcmd.RecommendedConfigPathFlag)
 	if err != nil {
 		return xerrors.Errorf("parse %s: %w", clientcmd.RecommendedConfigPathFlag, err)
@@ -89,6 +164,21 @@ func run(cmd *cobra.Command, _ []string) error {
 		return xerrors.Errorf("creating RawConfig: %w", err)
 	}
 
+	concurrency, err := cmd.Flags().GetInt("concurrency")
+	if err != nil {
+		return xerrors.Errorf("parse concurrency: %w", err)
+	}
+	if concurrency < 1 {
+		return xerrors.Errorf("parse concurrency: must be at least 1, got %d", concurrency)
+	}
+
+	// The client-go defaults (5 QPS, burst of 10) would serialize the
+	// concurrent RBAC checks, so raise them unless configured explicitly.
+	if config.QPS == 0 && config.Burst == 0 {
+		config.QPS = defaultQPS
+		config.Burst = defaultBurst
+	}
+
 	clientset, err := kclient.NewForConfig(config)
 	if err != nil {
 		return xerrors.Errorf("creating kube client from config: %w", err)
@@ -104,7 +194,79 @@ func run(cmd *cobra.Command, _ []string) error {
 		return xerrors.Errorf("parse coder-version from string %q: %w", coderVersion, err)
 	}
 
-	log := slog.Make(sloghuman.Sink(cmd.OutOrStdout()))
+	reqs, err := loadRequirements(cmd)
+	if err != nil {
+		return err
+	}
+	// The checkers panic on a version of Coder they have no requirements
+	// for, so reject it here with a usable error.
+	if err := reqs.CheckVersion(cv); err != nil {
+		return xerrors.Errorf("parse coder-version: %w", err)
+	}
+
+	failOnFlag, err := cmd.Flags().GetString("fail-on")
+	if err != nil {
+		return xerrors.Errorf("parse fail-on: %w", err)
+	}
+
+	failOn, err := api.ParseCheckState(failOnFlag)
+	if err != nil {
+		return xerrors.Errorf("parse fail-on: %w", err)
+	}
+	if failOn != api.StateWarning && failOn != api.StateFailed {
+		return xerrors.Errorf("parse fail-on: state %q is not one of: warn, fail", failOnFlag)
+	}
+
+	emitRBAC, err := cmd.Flags().GetString("emit-rbac")
+	if err != nil {
+		return xerrors.Errorf("parse emit-rbac: %w", err)
+	}
+
+	emitRBACUser, err := cmd.Flags().GetString("emit-rbac-user")
+	if err != nil {
+		return xerrors.Errorf("parse emit-rbac-user: %w", err)
+	}
+
+	workspaces, err := cmd.Flags().GetInt("workspaces")
+	if err != nil {
+		return xerrors.Errorf("parse workspaces: %w", err)
+	}
+	if workspaces < 0 {
+		return xerrors.Errorf("parse workspaces: must not be negative, got %d", workspaces)
+	}
+
+	smokeTests, err := cmd.Flags().GetBool("smoke-tests")
+	if err != nil {
+		return xerrors.Errorf("parse smoke-tests: %w", err)
+	}
+
+	smokeTestTimeout, err := cmd.Flags().GetDuration("smoke-test-timeout")
+	if err != nil {
+		return xerrors.Errorf("parse smoke-test-timeout: %w", err)
+	}
+	if smokeTestTimeout <= 0 {
+		return xerrors.Errorf("parse smoke-test-timeout: must be positive, got %s", smokeTestTimeout)
+	}
+
+	loadBalancerSmokeTest, err := cmd.Flags().GetBool("smoke-test-loadbalancer")
+	if err != nil {
+		return xerrors.Errorf("parse smoke-test-loadbalancer: %w", err)
+	}
+
+	loadBalancerSmokeTestTimeout, err := cmd.Flags().GetDuration("smoke-test-loadbalancer-timeout")
+	if err != nil {
+		return xerrors.Errorf("parse smoke-test-loadbalancer-timeout: %w", err)
+	}
+	if loadBalancerSmokeTestTimeout <= 0 {
+		return xerrors.Errorf("parse smoke-test-loadbalancer-timeout: must be positive, got %s", loadBalancerSmokeTestTimeout)
+	}
+
+	subjectOpt, err := getSubjectOptionFromFlags(cmd)
+	if err != nil {
+		return xerrors.Errorf("parse flags: %w", err)
+	}
+
+	log := slog.Make(sloghuman.Sink(cmd.ErrOrStderr()))
 	verbosity, err := cmd.Flags().GetInt("verbosity")
 	if err != nil {
 		return xerrors.Errorf("parse verbosity: %w", err)
@@ -121,41 +283,47 @@ func run(cmd *cobra.Command, _ []string) error {
 		currentContext.Namespace = "default"
 	}
 
-	colorFlag, err := cmd.Flags().GetBool("output-colors")
+	writer, err := output.NewWriter(cmd)
 	if err != nil {
-		return xerrors.Errorf("parse output-color: %w", err)
+		return xerrors.Errorf("create output writer: %w", err)
 	}
-
-	asciiFlag, err := cmd.Flags().GetBool("output-ascii")
-	if err != nil {
-		return xerrors.Errorf("parse output-ascii: %w", err)
-	}
-
-	outputMode := humanwriter.OutputModeEmoji
-	if asciiFlag {
-		outputMode = humanwriter.OutputModeText
-	}
-
-	var writer api.ResultWriter = humanwriter.New(
-		os.Stdout,
-		humanwriter.WithColors(colorFlag),
-		humanwriter.WithMode(outputMode),
-	)
+	defer func() {
+		// A failure to write output takes precedence over an exit code
+		// derived from the results, since the output is incomplete.
+		var exitErr *api.ExitError
+		if cerr := writer.Close(); cerr != nil && (err == nil || xerrors.As(err, &exitErr)) {
+			err = xerrors.Errorf("close output writer: %w", cerr)
+		}
+	}()
 
 	localChecker := local.NewChecker(
 		local.WithLogger(log),
 		local.WithCoderVersion(cv),
 		local.WithWriter(writer),
 		local.WithTarget(api.CheckTargetKubernetes),
+		local.WithRequirements(reqs),
 	)
 
-	kubeChecker := kube.NewKubernetesChecker(
-		clientset,
+	kubeOpts := []kube.Option{
 		kube.WithLogger(log),
 		kube.WithCoderVersion(cv),
 		kube.WithWriter(writer),
 		kube.WithNamespace(currentContext.Namespace),
-	)
+		kube.WithConcurrency(concurrency),
+		kube.WithWorkspaces(workspaces),
+		kube.WithRequirements(reqs),
+	}
+	if subjectOpt != nil {
+		kubeOpts = append(kubeOpts, subjectOpt)
+	}
+	if smokeTests {
+		kubeOpts = append(kubeOpts, kube.WithSmokeTests(smokeTestTimeout))
+	}
+	if loadBalancerSmokeTest {
+		kubeOpts = append(kubeOpts, kube.WithLoadBalancerSmokeTest(loadBalancerSmokeTestTimeout))
+	}
+
+	kubeChecker := kube.NewKubernetesChecker(clientset, kubeOpts...)
 
 	_ = writer.WriteResult(&api.CheckResult{
 		Name:    "kubernetes current-context",
@@ -185,5 +353,84 @@ func run(cmd *cobra.Command, _ []string) error {
 		return xerrors.Errorf("run kube checker: %w", err)
 	}
 
+	if emitRBAC != "" {
+		subject, ok := kubeChecker.Subject()
+		switch {
+		case emitRBACUser != "":
+			subject = rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: emitRBACUser}
+		case !ok:
+			subject = currentUserSubject(cmd, log, kubeChecker, rawConfig.AuthInfos[currentContext.AuthInfo])
+		}
+		if err := writeRBACManifest(cmd, log, kubeChecker, emitRBAC, subject); err != nil {
+			return xerrors.Errorf("emit rbac: %w", err)
+		}
+	}
+
+	summary := writer.Summary()
+	log.Info(cmd.Context(), "checks complete",
+		slog.F("passed", summary.Passed),
+		slog.F("warning", summary.Warning),
+		slog.F("failed", summary.Failed),
+		slog.F("info", summary.Info),
+		slog.F("skipped", summary.Skipped),
+		slog.F("total", summary.Total))
+
+	if code := summary.ExitCode(failOn); code != api.ExitCodeOK {
+		// The results have already been reported, so there is nothing
+		// more to print.
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &api.ExitError{Code: code}
+	}
+
+	return nil
+}
+
+// writeRBACManifest writes a manifest granting the permissions found to be
+// missing by the checker to the given path.
+// currentUserSubject returns the subject to bind the roles written by
+// --emit-rbac to when no subject is given. The current user is found
+// with a SelfSubjectReview, or from the kubeconfig if the cluster does not
+// support them. If neither works, a placeholder is returned.
+func currentUserSubject(cmd *cobra.Command, log slog.Logger, checker *kube.KubernetesChecker, authInfo *clientcmdapi.AuthInfo) rbacv1.Subject {
+	username, err := checker.CurrentUser(cmd.Context())
+	if err == nil {
+		return kube.UserSubject(username)
+	}
+	log.Debug(cmd.Context(), "unable to get current user from SelfSubjectReview", slog.Error(err))
+
+	// Only the kubeconfig fields naming the user are used, since the name
+	// of the kubeconfig user entry is often unrelated to the username.
+	if authInfo != nil {
+		if authInfo.Impersonate != "" {
+			return kube.UserSubject(authInfo.Impersonate)
+		}
+		if authInfo.Username != "" {
+			return kube.UserSubject(authInfo.Username)
+		}
+	}
+
+	log.Warn(cmd.Context(), "unable to determine the current user, binding RBAC manifest to a placeholder; "+
+		"use --emit-rbac-user to set the user",
+		slog.F("placeholder", kube.PlaceholderSubject().Name))
+	return kube.PlaceholderSubject()
+}
+
+func writeRBACManifest(cmd *cobra.Command, log slog.Logger, checker *kube.KubernetesChecker, path string, subject rbacv1.Subject) error {
+	manifest, err := checker.RBACManifest(subject)
+	if err != nil {
+		return xerrors.Errorf("generate rbac manifest: %w", err)
+	}
+
+	if manifest == nil {
+		log.Info(cmd.Context(), "no missing RBAC permissions, not writing manifest", slog.F("path", path))
+		return nil
+	}
+
+	if err := os.WriteFile(path, manifest, 0o600); err != nil {
+		return xerrors.Errorf("write rbac manifest: %w", err)
+	}
+
+	log.Info(cmd.Context(), "wrote RBAC manifest for missing permissions", slog.F("path", path))
 	return nil
 }
@@ -0,0 +1,198 @@
+package output
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/filterwriter"
+	"cdr.dev/coder-doctor/internal/humanwriter"
+	"cdr.dev/coder-doctor/internal/jsonwriter"
+	"cdr.dev/coder-doctor/internal/junitwriter"
+	"cdr.dev/coder-doctor/internal/markdownwriter"
+	"cdr.dev/coder-doctor/internal/multiwriter"
+	"cdr.dev/coder-doctor/internal/sarifwriter"
+	"cdr.dev/coder-doctor/internal/summarywriter"
+)
+
+// stdoutPath is the output path that refers to the command's output
+// stream rather than a file.
+const stdoutPath = "-"
+
+// Formats is the list of supported output formats.
+var Formats = []string{"human", "json", "junit", "markdown", "sarif"}
+
+var _ = api.ResultWriteCloser(&Writer{})
+
+// Writer is the ResultWriter configured by the output flags. It writes
+// to every selected output, and counts all results (including those
+// hidden from the outputs) so callers can inspect the Summary once the
+// checks complete.
+type Writer struct {
+	*summarywriter.SummaryWriter
+	files []*os.File
+}
+
+// Close closes all configured writers, flushing buffered output, and
+// then closes any files that were opened for output.
+func (w *Writer) Close() error {
+	err := w.SummaryWriter.Close()
+	if cerr := w.closeFiles(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// outputSpec is a parsed value of the --output flag, of the form
+// "format" or "format=path".
+type outputSpec struct {
+	Format string
+	Path   string
+}
+
+func parseSpec(spec, defaultPath string) (outputSpec, error) {
+	format, path := spec, defaultPath
+	if i := strings.Index(spec, "="); i >= 0 {
+		format, path = spec[:i], spec[i+1:]
+	}
+
+	format = strings.ToLower(strings.TrimSpace(format))
+	for _, f := range Formats {
+		if f == format {
+			if path == "" {
+				return outputSpec{}, xerrors.Errorf("output %q: empty path", spec)
+			}
+			return outputSpec{Format: format, Path: path}, nil
+		}
+	}
+
+	return outputSpec{}, xerrors.Errorf("output %q: unknown format %q, must be one of: %s",
+		spec, format, strings.Join(Formats, ", "))
+}
+
+// NewWriter creates a Writer from the output flags defined on the root
+// command.
+func NewWriter(cmd *cobra.Command) (*Writer, error) {
+	outputs, err := cmd.Flags().GetStringSlice("output")
+	if err != nil {
+		return nil, xerrors.Errorf("parse output: %w", err)
+	}
+
+	outputFile, err := cmd.Flags().GetString("output-file")
+	if err != nil {
+		return nil, xerrors.Errorf("parse output-file: %w", err)
+	}
+	if outputFile == "" {
+		outputFile = stdoutPath
+	}
+
+	hidden, err := cmd.Flags().GetStringSlice("output-hide")
+	if err != nil {
+		return nil, xerrors.Errorf("parse output-hide: %w", err)
+	}
+
+	colorFlag, err := cmd.Flags().GetBool("output-colors")
+	if err != nil {
+		return nil, xerrors.Errorf("parse output-colors: %w", err)
+	}
+
+	asciiFlag, err := cmd.Flags().GetBool("output-ascii")
+	if err != nil {
+		return nil, xerrors.Errorf("parse output-ascii: %w", err)
+	}
+
+	if len(outputs) == 0 {
+		return nil, xerrors.New("at least one output is required")
+	}
+
+	specs := make([]outputSpec, 0, len(outputs))
+	seen := make(map[string]string)
+	for _, o := range outputs {
+		spec, err := parseSpec(o, outputFile)
+		if err != nil {
+			return nil, err
+		}
+		if other, ok := seen[spec.Path]; ok {
+			return nil, xerrors.Errorf("outputs %q and %q both write to %q", other, o, spec.Path)
+		}
+		seen[spec.Path] = o
+		specs = append(specs, spec)
+	}
+
+	filterOpts := make([]filterwriter.Option, 0, len(hidden)+2)
+	// Results of every state are written unless explicitly hidden.
+	filterOpts = append(filterOpts,
+		filterwriter.WithAcceptState(api.StateInfo),
+		filterwriter.WithAcceptState(api.StateSkipped))
+	for _, name := range hidden {
+		state, err := api.ParseCheckState(name)
+		if err != nil {
+			return nil, xerrors.Errorf("parse output-hide: %w", err)
+		}
+		filterOpts = append(filterOpts, filterwriter.WithFilterState(state))
+	}
+
+	w := &Writer{}
+	writers := make([]api.ResultWriter, 0, len(specs))
+	for _, spec := range specs {
+		var out io.Writer = cmd.OutOrStdout()
+		colors := colorFlag
+		if spec.Path != stdoutPath {
+			f, err := os.Create(spec.Path)
+			if err != nil {
+				_ = w.closeFiles()
+				return nil, xerrors.Errorf("create output file: %w", err)
+			}
+			w.files = append(w.files, f)
+			out = f
+			colors = false
+		}
+
+		writers = append(writers, newFormatWriter(spec.Format, out, colors, asciiFlag))
+	}
+
+	filter, err := filterwriter.New(multiwriter.New(writers...), filterOpts...)
+	if err != nil {
+		_ = w.closeFiles()
+		return nil, xerrors.Errorf("create filter: %w", err)
+	}
+
+	w.SummaryWriter = summarywriter.New(filter)
+	return w, nil
+}
+
+func newFormatWriter(format string, out io.Writer, colors, ascii bool) api.ResultWriter {
+	switch format {
+	case "json":
+		return jsonwriter.New(out)
+	case "junit":
+		return junitwriter.New(out)
+	case "markdown":
+		return markdownwriter.New(out)
+	case "sarif":
+		return sarifwriter.New(out)
+	}
+
+	mode := humanwriter.OutputModeEmoji
+	if ascii {
+		mode = humanwriter.OutputModeText
+	}
+	return humanwriter.New(out,
+		humanwriter.WithColors(colors),
+		humanwriter.WithMode(mode),
+	)
+}
+
+func (w *Writer) closeFiles() error {
+	var err error
+	for _, f := range w.files {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = xerrors.Errorf("close output file %q: %w", f.Name(), cerr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,210 @@
+package output_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/cmd/output"
+)
+
+func TestNewWriter(t *testing.T) {
+	t.Parallel()
+
+	results := []*api.CheckResult{
+		api.PassResult("check-pass", "it passed"),
+		api.WarnResult("check-warn", "it warned"),
+		api.SkippedResult("check-skip", "it was skipped", nil),
+	}
+
+	tests := []struct {
+		Name string
+		// Args are the command line flags, in which "DIR" is replaced by
+		// a temporary directory.
+		Args []string
+		// Stdout lists substrings expected in the command's output.
+		Stdout []string
+		// NotStdout lists substrings not expected in the command's output.
+		NotStdout []string
+		// Files maps file names in the temporary directory to substrings
+		// expected in their contents.
+		Files map[string][]string
+	}{
+		{
+			Name:   "default",
+			Args:   nil,
+			Stdout: []string{"it passed", "it warned", "it was skipped"},
+		},
+		{
+			Name:   "format",
+			Args:   []string{"-o", "json"},
+			Stdout: []string{`{"schemaVersion":1}`, `"name":"check-pass"`},
+		},
+		{
+			Name:      "multiple files",
+			Args:      []string{"-o", "human", "-o", "json=DIR/results.json", "-o", "junit=DIR/results.xml"},
+			Stdout:    []string{"it passed"},
+			NotStdout: []string{"schemaVersion", "<testsuite"},
+			Files: map[string][]string{
+				"results.json": {`{"schemaVersion":1}`, `"name":"check-warn"`},
+				"results.xml":  {"<testsuite", "check-warn"},
+			},
+		},
+		{
+			Name:   "output file",
+			Args:   []string{"-o", "markdown", "-o", "sarif=-", "--output-file", "DIR/results.md"},
+			Stdout: []string{`"version": "2.1.0"`},
+			Files: map[string][]string{
+				"results.md": {"it passed"},
+			},
+		},
+		{
+			Name:      "hide states",
+			Args:      []string{"-o", "json", "--output-hide", "pass,skip"},
+			Stdout:    []string{`"name":"check-warn"`},
+			NotStdout: []string{"check-pass", "check-skip"},
+		},
+		{
+			Name:      "hide all states",
+			Args:      []string{"-o", "json", "--output-hide", "pass,warn,skip"},
+			Stdout:    []string{`{"schemaVersion":1}`},
+			NotStdout: []string{"check-"},
+		},
+		{
+			Name:   "case insensitive format",
+			Args:   []string{"-o", "JSON=DIR/results.json"},
+			Stdout: nil,
+			Files: map[string][]string{
+				"results.json": {`"name":"check-skip"`},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			cmd, stdout := newCommand(t, dir, test.Args...)
+
+			w, err := output.NewWriter(cmd)
+			assert.Success(t, "create writer", err)
+			for _, result := range results {
+				assert.Success(t, "write result", w.WriteResult(result))
+			}
+			assert.Success(t, "close writer", w.Close())
+
+			// Hidden results are still counted.
+			assert.Equal(t, "total", 3, w.Summary().Total)
+
+			for _, s := range test.Stdout {
+				assert.True(t, fmt.Sprintf("stdout contains %q", s), strings.Contains(stdout.String(), s))
+			}
+			for _, s := range test.NotStdout {
+				assert.False(t, fmt.Sprintf("stdout does not contain %q", s), strings.Contains(stdout.String(), s))
+			}
+			for name, contents := range test.Files {
+				b, err := os.ReadFile(filepath.Join(dir, name))
+				assert.Success(t, "read "+name, err)
+				for _, s := range contents {
+					assert.True(t, fmt.Sprintf("%s contains %q", name, s), strings.Contains(string(b), s))
+				}
+			}
+		})
+	}
+}
+
+func TestNewWriter_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name  string
+		Args  []string
+		Error string
+	}{
+		{
+			Name:  "unknown format",
+			Args:  []string{"-o", "yaml"},
+			Error: `output "yaml": unknown format "yaml", must be one of: human, json, junit, markdown, sarif`,
+		},
+		{
+			Name:  "empty path",
+			Args:  []string{"-o", "json="},
+			Error: `output "json=": empty path`,
+		},
+		{
+			Name:  "duplicate stdout",
+			Args:  []string{"-o", "human", "-o", "json"},
+			Error: `outputs "human" and "json" both write to "-"`,
+		},
+		{
+			Name:  "duplicate file",
+			Args:  []string{"-o", "json=DIR/out", "-o", "junit=DIR/out"},
+			Error: "both write to",
+		},
+		{
+			Name:  "duplicate output file",
+			Args:  []string{"-o", "json", "-o", "junit=DIR/out", "--output-file", "DIR/out"},
+			Error: "both write to",
+		},
+		{
+			Name:  "unknown hidden state",
+			Args:  []string{"--output-hide", "passed,bogus"},
+			Error: `parse output-hide: unknown state: "bogus"`,
+		},
+		{
+			Name:  "no outputs",
+			Args:  []string{"-o", ""},
+			Error: "at least one output is required",
+		},
+		{
+			Name:  "create file",
+			Args:  []string{"-o", "json=DIR/missing/out.json"},
+			Error: "create output file",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd, _ := newCommand(t, t.TempDir(), test.Args...)
+			_, err := output.NewWriter(cmd)
+			assert.ErrorContains(t, "error", err, test.Error)
+		})
+	}
+}
+
+// newCommand returns a command with the output flags of the root command,
+// parsed from args with "DIR" replaced by dir, and a buffer holding its
+// output.
+func newCommand(t *testing.T, dir string, args ...string) (*cobra.Command, *bytes.Buffer) {
+	t.Helper()
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("output-colors", true, "")
+	cmd.Flags().Bool("output-ascii", false, "")
+	cmd.Flags().StringSliceP("output", "o", []string{"human"}, "")
+	cmd.Flags().String("output-file", "", "")
+	cmd.Flags().StringSlice("output-hide", nil, "")
+
+	expanded := make([]string, 0, len(args))
+	for _, arg := range args {
+		expanded = append(expanded, strings.ReplaceAll(arg, "DIR", dir))
+	}
+	assert.Success(t, "parse flags", cmd.ParseFlags(expanded))
+
+	var stdout bytes.Buffer
+	cmd.SetOut(&stdout)
+	return cmd, &stdout
+}
@@ -0,0 +1,78 @@
+package requirements
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+func NewCommand() *cobra.Command {
+	requirementsCmd := &cobra.Command{
+		Use:   "requirements",
+		Short: "print the requirements of each version of Coder",
+		Args:  cobra.NoArgs,
+		RunE:  run,
+	}
+
+	requirementsCmd.Flags().String("format", "table",
+		fmt.Sprintf("output format (one of: %s)", strings.Join(requirements.MatrixFormats, ", ")))
+	requirementsCmd.Flags().String("coder-version", "", "only print the requirements of this version of Coder")
+	requirementsCmd.Flags().String("requirements-file", "", "path to a requirements manifest to use instead of the built-in requirements")
+
+	return requirementsCmd
+}
+
+func run(cmd *cobra.Command, _ []string) error {
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return xerrors.Errorf("parse format: %w", err)
+	}
+
+	path, err := cmd.Flags().GetString("requirements-file")
+	if err != nil {
+		return xerrors.Errorf("parse requirements-file: %w", err)
+	}
+
+	manifest := requirements.Default()
+	if path != "" {
+		manifest, err = requirements.Load(path)
+		if err != nil {
+			return xerrors.Errorf("load requirements: %w", err)
+		}
+	}
+
+	entries := manifest.Matrix()
+
+	coderVersion, err := cmd.Flags().GetString("coder-version")
+	if err != nil {
+		return xerrors.Errorf("parse coder-version: %w", err)
+	}
+
+	if coderVersion != "" {
+		cv, err := semver.NewVersion(coderVersion)
+		if err != nil {
+			return xerrors.Errorf("parse coder-version from string %q: %w", coderVersion, err)
+		}
+
+		// Like the checks, select the newest listed version of Coder that
+		// is not newer than the requested version.
+		selected := -1
+		for i, c := range manifest.Coder {
+			if !c.Version.GreaterThan(cv) {
+				selected = i
+				break
+			}
+		}
+		if selected < 0 {
+			return xerrors.Errorf("unhandled coder version: %s", cv)
+		}
+		entries = entries[selected : selected+1]
+	}
+
+	return requirements.WriteMatrix(cmd.OutOrStdout(), format, entries)
+}
@@ -1,9 +1,14 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/spf13/cobra"
 
 	"cdr.dev/coder-doctor/internal/cmd/check"
+	"cdr.dev/coder-doctor/internal/cmd/output"
+	"cdr.dev/coder-doctor/internal/cmd/requirements"
 	"cdr.dev/coder-doctor/internal/cmd/version"
 )
 
@@ -17,10 +22,15 @@ func NewDefaultDoctorCommand() *cobra.Command {
 	rootCmd.AddCommand(
 		version.NewCommand(),
 		check.NewCommand(),
+		requirements.NewCommand(),
 	)
 
 	rootCmd.PersistentFlags().Bool("output-colors", true, "enable colorful output")
 	rootCmd.PersistentFlags().Bool("output-ascii", false, "output ascii only")
+	rootCmd.PersistentFlags().StringSliceP("output", "o", []string{"human"},
+		fmt.Sprintf("output formats, as format or format=path; may be repeated (one of: %s)", strings.Join(output.Formats, ", ")))
+	rootCmd.PersistentFlags().String("output-file", "", "write outputs without an explicit path to this file instead of stdout")
+	rootCmd.PersistentFlags().StringSlice("output-hide", nil, "result states to omit from output (e.g. pass,info,skip)")
 
 	return rootCmd
 }
@@ -58,3 +58,8 @@ func (w *FilterWriter) WriteResult(result *api.CheckResult) error {
 
 	return nil
 }
+
+// Close closes the wrapped writer, if it supports closing.
+func (w *FilterWriter) Close() error {
+	return api.CloseWriter(w.writer)
+}
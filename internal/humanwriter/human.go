@@ -3,6 +3,7 @@ package humanwriter
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"cdr.dev/coder-doctor/internal/api"
 )
@@ -88,5 +89,33 @@ func (w *HumanResultWriter) WriteResult(result *api.CheckResult) error {
 	}
 
 	_, err = fmt.Fprintln(w.out, printFunc(prefix), result.Summary)
-	return err
+	if err != nil {
+		return err
+	}
+
+	if result.State != api.StateFailed && result.State != api.StateWarning {
+		return nil
+	}
+
+	return w.writeRemediation(result)
+}
+
+// writeRemediation writes the remediation and documentation link, if
+// any, indented below the result summary.
+func (w *HumanResultWriter) writeRemediation(result *api.CheckResult) error {
+	if result.Remediation != "" {
+		for _, line := range strings.Split(strings.TrimSpace(result.Remediation), "\n") {
+			if _, err := fmt.Fprintln(w.out, "   ", line); err != nil {
+				return err
+			}
+		}
+	}
+
+	if result.DocsURL != "" {
+		if _, err := fmt.Fprintln(w.out, "    see", result.DocsURL); err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
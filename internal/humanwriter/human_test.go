@@ -37,8 +37,16 @@ func TestHumanWriter(t *testing.T) {
 				Summary: "summary",
 			})
 			writer.WriteResult(&api.CheckResult{
-				State:   api.StateFailed,
-				Summary: "failed message",
+				State:       api.StateFailed,
+				Summary:     "failed message",
+				Remediation: "run this\nthen this",
+				DocsURL:     "https://example.com/docs",
+			})
+			writer.WriteResult(&api.CheckResult{
+				State:       api.StatePassed,
+				Summary:     "passed with docs",
+				Remediation: "not shown",
+				DocsURL:     "https://example.com/docs",
 			})
 			writer.WriteResult(&api.CheckResult{
 				State:   api.StateWarning,
@@ -55,12 +63,20 @@ func TestHumanWriter(t *testing.T) {
 				expected = "✓ human writer check test\n" +
 					"🔔 summary\n" +
 					"✗ failed message\n" +
+					"    run this\n" +
+					"    then this\n" +
+					"    see https://example.com/docs\n" +
+					"✓ passed with docs\n" +
 					"⚠️ \n" +
 					"⏩ skipped check\n"
 			case humanwriter.OutputModeText:
 				expected = "PASS human writer check test\n" +
 					"INFO summary\n" +
 					"FAIL failed message\n" +
+					"    run this\n" +
+					"    then this\n" +
+					"    see https://example.com/docs\n" +
+					"PASS passed with docs\n" +
 					"WARN \n" +
 					"SKIP skipped check\n"
 			}
@@ -4,16 +4,31 @@ import (
 	"encoding/json"
 	"io"
 
+	"golang.org/x/xerrors"
+
 	"cdr.dev/coder-doctor/internal/api"
 )
 
-var _ = api.ResultWriter(&JSONWriter{})
+var _ = api.ResultWriteCloser(&JSONWriter{})
+
+// SchemaVersion is the version of the JSON output format described by
+// Schema. It must be incremented whenever a change could break existing
+// consumers, such as removing or renaming a field.
+const SchemaVersion = 1
+
+// Header is written as the first line of the stream, before any
+// results, to identify the format of the stream.
+type Header struct {
+	SchemaVersion int `json:"schemaVersion"`
+}
 
 // JSONWriter is a writer that writes results to a stream in
-// JSON Lines format.
+// JSON Lines format. The first line of the stream is a Header, which is
+// written even if there are no results once the writer is closed.
 type JSONWriter struct {
-	writer  io.Writer
-	encoder *json.Encoder
+	writer        io.Writer
+	encoder       *json.Encoder
+	headerWritten bool
 }
 
 func New(writer io.Writer) *JSONWriter {
@@ -24,5 +39,27 @@ func New(writer io.Writer) *JSONWriter {
 }
 
 func (w *JSONWriter) WriteResult(result *api.CheckResult) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+
 	return w.encoder.Encode(result)
 }
+
+// Close writes the header if no results were written, so that an empty
+// stream still identifies its format. It does not close the underlying
+// writer.
+func (w *JSONWriter) Close() error {
+	return w.writeHeader()
+}
+
+func (w *JSONWriter) writeHeader() error {
+	if w.headerWritten {
+		return nil
+	}
+	if err := w.encoder.Encode(Header{SchemaVersion: SchemaVersion}); err != nil {
+		return xerrors.Errorf("write header: %w", err)
+	}
+	w.headerWritten = true
+	return nil
+}
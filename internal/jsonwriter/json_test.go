@@ -23,7 +23,8 @@ func TestJSONWriter(t *testing.T) {
 			"string": "hello world",
 		},
 	})
-	expected := `{"name":"checks","state":2,"summary":"test check","details":{"number":123,"string":"hello world"}}` + "\n"
+	expected := `{"schemaVersion":1}` + "\n" +
+		`{"name":"checks","state":"FAIL","summary":"test check","details":{"number":123,"string":"hello world"}}` + "\n"
 	assert.Equal(t, "check with details", expected, buf.String())
 	buf.Reset()
 
@@ -32,6 +33,21 @@ func TestJSONWriter(t *testing.T) {
 		Name:    "checks",
 		Summary: "test passing check",
 	})
-	expected = `{"name":"checks","state":0,"summary":"test passing check"}` + "\n"
+	expected = `{"name":"checks","state":"PASS","summary":"test passing check"}` + "\n"
 	assert.Equal(t, "check without details", expected, buf.String())
 }
+
+func TestJSONWriter_Empty(t *testing.T) {
+	t.Parallel()
+
+	var buf strings.Builder
+	w := jsonwriter.New(&buf)
+	err := w.Close()
+	assert.Success(t, "close", err)
+	assert.Equal(t, "header only", `{"schemaVersion":1}`+"\n", buf.String())
+
+	// Closing again does not repeat the header.
+	err = w.Close()
+	assert.Success(t, "close again", err)
+	assert.Equal(t, "header only once", `{"schemaVersion":1}`+"\n", buf.String())
+}
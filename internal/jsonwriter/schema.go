@@ -0,0 +1,144 @@
+package jsonwriter
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+var checkStateType = reflect.TypeOf(api.CheckState(0))
+
+// Schema returns a JSON Schema describing each line written by a
+// JSONWriter. It is generated from the Header and api.CheckResult
+// types, and published in the schema directory of the repository.
+func Schema() ([]byte, error) {
+	header, err := typeSchema(reflect.TypeOf(Header{}))
+	if err != nil {
+		return nil, xerrors.Errorf("generate header schema: %w", err)
+	}
+
+	result, err := typeSchema(reflect.TypeOf(api.CheckResult{}))
+	if err != nil {
+		return nil, xerrors.Errorf("generate result schema: %w", err)
+	}
+
+	doc := map[string]interface{}{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title":   "coder-doctor JSON output",
+		"description": "Each line of coder-doctor JSON output is a JSON document. " +
+			"The first line is a header identifying the schema version, and " +
+			"each following line is a check result.",
+		"oneOf": []interface{}{
+			map[string]interface{}{"$ref": "#/definitions/header"},
+			map[string]interface{}{"$ref": "#/definitions/result"},
+		},
+		"definitions": map[string]interface{}{
+			"header": header,
+			"result": result,
+		},
+	}
+
+	out, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, xerrors.Errorf("marshal schema: %w", err)
+	}
+
+	return append(out, '\n'), nil
+}
+
+// typeSchema returns the JSON Schema for the given type, following the
+// rules used by encoding/json to marshal it.
+func typeSchema(t reflect.Type) (map[string]interface{}, error) {
+	if t == checkStateType {
+		names := make([]string, 0, len(api.AllStates))
+		for _, state := range api.AllStates {
+			names = append(names, state.MustText())
+		}
+		return map[string]interface{}{
+			"type": "string",
+			"enum": names,
+		}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchema(t.Elem())
+	case reflect.Interface:
+		// Any value is permitted.
+		return map[string]interface{}{}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+	case reflect.Slice, reflect.Array:
+		items, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		values, err := typeSchema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return nil, xerrors.Errorf("unsupported type %s", t)
+}
+
+func structSchema(t reflect.Type) (map[string]interface{}, error) {
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			// unexported
+			continue
+		}
+
+		name := field.Name
+		omitEmpty := false
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			parts := strings.Split(tag, ",")
+			if parts[0] == "-" {
+				continue
+			}
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			for _, opt := range parts[1:] {
+				if opt == "omitempty" {
+					omitEmpty = true
+				}
+			}
+		}
+
+		schema, err := typeSchema(field.Type)
+		if err != nil {
+			return nil, xerrors.Errorf("field %s: %w", field.Name, err)
+		}
+		properties[name] = schema
+		if !omitEmpty {
+			required = append(required, name)
+		}
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+		"required":   required,
+	}, nil
+}
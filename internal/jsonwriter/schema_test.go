@@ -0,0 +1,36 @@
+package jsonwriter_test
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"cdr.dev/coder-doctor/internal/jsonwriter"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+var update = flag.Bool("update", false, "update the published JSON schema")
+
+// TestSchema verifies that the published schema matches the one
+// generated from the current types. To regenerate it, run:
+//
+//	go test ./internal/jsonwriter -run TestSchema -update
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join("..", "..", "schema", "check-result.v1.json")
+
+	generated, err := jsonwriter.Schema()
+	assert.Success(t, "generate schema", err)
+
+	if *update {
+		// The schema is checked in and published, so keep it readable.
+		err := os.WriteFile(path, generated, 0o644) //nolint:gosec
+		assert.Success(t, "write schema", err)
+	}
+
+	published, err := os.ReadFile(path)
+	assert.Success(t, "read published schema", err)
+	assert.Equal(t, "published schema is up to date", string(generated), string(published))
+}
@@ -0,0 +1,196 @@
+package junitwriter
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+var _ = api.ResultWriteCloser(&JUnitWriter{})
+
+const defaultName = "coder-doctor"
+
+// JUnitWriter is a writer that collects results and writes them to a
+// stream as a JUnit XML report when closed. Each check name becomes a
+// test suite, and each result a test case within that suite.
+type JUnitWriter struct {
+	writer io.Writer
+	name   string
+	suites []*testSuite
+	closed bool
+}
+
+type Option func(w *JUnitWriter)
+
+func New(writer io.Writer, opts ...Option) *JUnitWriter {
+	w := &JUnitWriter{
+		writer: writer,
+		name:   defaultName,
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// WithName sets the name of the top-level testsuites element, which is
+// also used as the suite name for results without a check name.
+func WithName(name string) Option {
+	return func(w *JUnitWriter) {
+		w.name = name
+	}
+}
+
+type testSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Suites   []*testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	Name     string     `xml:"name,attr"`
+	Tests    int        `xml:"tests,attr"`
+	Failures int        `xml:"failures,attr"`
+	Skipped  int        `xml:"skipped,attr"`
+	Cases    []testCase `xml:"testcase"`
+}
+
+type testCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Failure   *failure `xml:"failure,omitempty"`
+	Skipped   *skipped `xml:"skipped,omitempty"`
+	SystemOut string   `xml:"system-out,omitempty"`
+}
+
+type failure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+}
+
+type skipped struct {
+	Message string `xml:"message,attr"`
+}
+
+func (w *JUnitWriter) WriteResult(result *api.CheckResult) error {
+	if w.closed {
+		return xerrors.New("write to closed junit writer")
+	}
+
+	text, err := result.State.Text()
+	if err != nil {
+		return err
+	}
+
+	suiteName := result.Name
+	if suiteName == "" {
+		suiteName = w.name
+	}
+
+	tc := testCase{
+		Name:      result.Summary,
+		ClassName: suiteName,
+		SystemOut: formatDetails(text, result.Details),
+	}
+	if tc.Name == "" {
+		tc.Name = text
+	}
+
+	suite := w.suite(suiteName)
+	suite.Tests++
+
+	switch result.State {
+	case api.StateFailed:
+		suite.Failures++
+		tc.Failure = &failure{
+			Message: result.Summary,
+			Type:    text,
+		}
+	case api.StateSkipped:
+		suite.Skipped++
+		tc.Skipped = &skipped{
+			Message: result.Summary,
+		}
+	}
+
+	suite.Cases = append(suite.Cases, tc)
+	return nil
+}
+
+// Close writes the JUnit XML report for all results written so far.
+// No further results may be written after the writer is closed.
+func (w *JUnitWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	report := testSuites{
+		Name:   w.name,
+		Suites: w.suites,
+	}
+	for _, suite := range w.suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+
+	out, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return xerrors.Errorf("marshal junit report: %w", err)
+	}
+
+	if _, err := io.WriteString(w.writer, xml.Header); err != nil {
+		return xerrors.Errorf("write junit header: %w", err)
+	}
+
+	if _, err := fmt.Fprintf(w.writer, "%s\n", out); err != nil {
+		return xerrors.Errorf("write junit report: %w", err)
+	}
+
+	return nil
+}
+
+// suite returns the test suite with the given name, creating it if
+// required. Suites are kept in the order they are first seen.
+func (w *JUnitWriter) suite(name string) *testSuite {
+	for _, suite := range w.suites {
+		if suite.Name == name {
+			return suite
+		}
+	}
+
+	suite := &testSuite{Name: name}
+	w.suites = append(w.suites, suite)
+	return suite
+}
+
+// formatDetails renders the result state and details as one
+// "key: value" pair per line, sorted by key.
+func formatDetails(state string, details map[string]interface{}) string {
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "state: %s\n", state)
+
+	keys := make([]string, 0, len(details))
+	for key := range details {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(&sb, "%s: %v\n", key, details[key])
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,64 @@
+package junitwriter_test
+
+import (
+	"strings"
+	"testing"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/junitwriter"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func TestJUnitWriter(t *testing.T) {
+	t.Parallel()
+
+	var sb strings.Builder
+	w := junitwriter.New(&sb)
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-version",
+		State:   api.StatePassed,
+		Summary: "version ok",
+		Details: map[string]interface{}{
+			"major": "1",
+			"minor": "21",
+		},
+	})
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-rbac",
+		State:   api.StateFailed,
+		Summary: "cannot create pods",
+	})
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-rbac",
+		State:   api.StateSkipped,
+		Summary: "skipped check",
+	})
+	assert.Equal(t, "nothing written before close", "", sb.String())
+
+	err := w.Close()
+	assert.Success(t, "close writer", err)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites name="coder-doctor" tests="3" failures="1" skipped="1">
+  <testsuite name="kubernetes-version" tests="1" failures="0" skipped="0">
+    <testcase name="version ok" classname="kubernetes-version">
+      <system-out>state: PASS&#xA;major: 1&#xA;minor: 21&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="kubernetes-rbac" tests="2" failures="1" skipped="1">
+    <testcase name="cannot create pods" classname="kubernetes-rbac">
+      <failure message="cannot create pods" type="FAIL"></failure>
+      <system-out>state: FAIL&#xA;</system-out>
+    </testcase>
+    <testcase name="skipped check" classname="kubernetes-rbac">
+      <skipped message="skipped check"></skipped>
+      <system-out>state: SKIP&#xA;</system-out>
+    </testcase>
+  </testsuite>
+</testsuites>
+`
+	assert.Equal(t, "junit report matches", expected, sb.String())
+
+	err = w.WriteResult(&api.CheckResult{State: api.StatePassed})
+	assert.ErrorContains(t, "write after close", err, "closed")
+}
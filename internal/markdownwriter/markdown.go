@@ -0,0 +1,54 @@
+package markdownwriter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+var _ = api.ResultWriter(&MarkdownWriter{})
+
+// MarkdownWriter is a writer that writes results to a stream as rows
+// of a Markdown table, suitable for pasting into issues or wiki pages.
+type MarkdownWriter struct {
+	writer        io.Writer
+	headerWritten bool
+}
+
+func New(writer io.Writer) *MarkdownWriter {
+	return &MarkdownWriter{
+		writer: writer,
+	}
+}
+
+var cellReplacer = strings.NewReplacer(
+	"|", `\|`,
+	"\r\n", "<br>",
+	"\n", "<br>",
+)
+
+// escapeCell makes the given text safe to use in a table cell.
+func escapeCell(s string) string {
+	return cellReplacer.Replace(strings.TrimSpace(s))
+}
+
+func (w *MarkdownWriter) WriteResult(result *api.CheckResult) error {
+	text, err := result.State.Text()
+	if err != nil {
+		return err
+	}
+
+	if !w.headerWritten {
+		_, err := io.WriteString(w.writer, "| State | Check | Summary |\n| --- | --- | --- |\n")
+		if err != nil {
+			return err
+		}
+		w.headerWritten = true
+	}
+
+	_, err = fmt.Fprintf(w.writer, "| %s | %s | %s |\n",
+		text, escapeCell(result.Name), escapeCell(result.Summary))
+	return err
+}
@@ -0,0 +1,35 @@
+package markdownwriter_test
+
+import (
+	"strings"
+	"testing"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/markdownwriter"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func TestMarkdownWriter(t *testing.T) {
+	t.Parallel()
+
+	var sb strings.Builder
+	w := markdownwriter.New(&sb)
+	assert.Equal(t, "no header before first result", "", sb.String())
+
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-version",
+		State:   api.StatePassed,
+		Summary: "Coder 1.21.0 supports Kubernetes 1.19.0 to 1.22.0",
+	})
+	w.WriteResult(&api.CheckResult{
+		Name:    "local-helm-version",
+		State:   api.StateFailed,
+		Summary: "requires Helm >= 3.6.0\nconstraint failed: a | b\n",
+	})
+
+	expected := "| State | Check | Summary |\n" +
+		"| --- | --- | --- |\n" +
+		"| PASS | kubernetes-version | Coder 1.21.0 supports Kubernetes 1.19.0 to 1.22.0 |\n" +
+		"| FAIL | local-helm-version | requires Helm >= 3.6.0<br>constraint failed: a \\| b |\n"
+	assert.Equal(t, "markdown table matches", expected, sb.String())
+}
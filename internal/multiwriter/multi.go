@@ -0,0 +1,44 @@
+package multiwriter
+
+import (
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+var _ = api.ResultWriteCloser(&MultiWriter{})
+
+// MultiWriter is a writer that duplicates each result to all of the
+// given writers, similar to io.MultiWriter.
+type MultiWriter struct {
+	writers []api.ResultWriter
+}
+
+func New(writers ...api.ResultWriter) *MultiWriter {
+	return &MultiWriter{
+		writers: writers,
+	}
+}
+
+// WriteResult writes the result to each writer in turn, stopping at
+// the first error.
+func (w *MultiWriter) WriteResult(result *api.CheckResult) error {
+	for _, writer := range w.writers {
+		if err := writer.WriteResult(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes every writer that supports closing. All writers are
+// closed even if one fails, and the first error is returned.
+func (w *MultiWriter) Close() error {
+	var firstErr error
+	for _, writer := range w.writers {
+		if err := api.CloseWriter(writer); err != nil && firstErr == nil {
+			firstErr = xerrors.Errorf("close writer: %w", err)
+		}
+	}
+	return firstErr
+}
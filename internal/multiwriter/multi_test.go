@@ -0,0 +1,39 @@
+package multiwriter_test
+
+import (
+	"strings"
+	"testing"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/humanwriter"
+	"cdr.dev/coder-doctor/internal/junitwriter"
+	"cdr.dev/coder-doctor/internal/multiwriter"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func TestMultiWriter(t *testing.T) {
+	t.Parallel()
+
+	var human, junit strings.Builder
+	capture := &api.CaptureWriter{}
+	w := multiwriter.New(
+		humanwriter.New(&human),
+		junitwriter.New(&junit),
+		capture,
+	)
+
+	result := &api.CheckResult{
+		Name:    "check-test",
+		State:   api.StatePassed,
+		Summary: "multi writer check test",
+	}
+	err := w.WriteResult(result)
+	assert.Success(t, "write result", err)
+	assert.Equal(t, "human output written", "PASS multi writer check test\n", human.String())
+	assert.Equal(t, "result captured", result, capture.Get()[0])
+	assert.Equal(t, "junit output buffered", "", junit.String())
+
+	err = w.Close()
+	assert.Success(t, "close writers", err)
+	assert.True(t, "junit output flushed on close", strings.Contains(junit.String(), "multi writer check test"))
+}
@@ -0,0 +1,178 @@
+package requirements
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/xerrors"
+)
+
+// MatrixEntry is the complete set of requirements for a version of Coder.
+type MatrixEntry struct {
+	CoderVersion      string                 `json:"coderVersion"`
+	Kubernetes        KubernetesRequirements `json:"kubernetes"`
+	Helm              Constraint             `json:"helm"`
+	Resources         []Resource             `json:"resources"`
+	RoleOnlyResources []Resource             `json:"roleOnlyResources"`
+	Nodes             *NodeRequirements      `json:"nodes,omitempty"`
+}
+
+// ResourcesFor returns the set of resources required by the given version
+// of Coder, or nil if there is none.
+func (m *Manifest) ResourcesFor(v *semver.Version) *ResourceSet {
+	for i := range m.Resources {
+		if m.Resources[i].Constraint.Check(v) {
+			return &m.Resources[i]
+		}
+	}
+	return nil
+}
+
+// CheckVersion returns an error unless the manifest has requirements for
+// the given version of Coder: a listed version of Coder no newer than it,
+// and a set of resources.
+func (m *Manifest) CheckVersion(v *semver.Version) error {
+	if len(m.Coder) == 0 || m.Coder[len(m.Coder)-1].Version.GreaterThan(v) {
+		return xerrors.Errorf("no requirements for coder version %s: no listed version of Coder is older", v)
+	}
+	if m.ResourcesFor(v) == nil {
+		return xerrors.Errorf("no resources for coder version %s: no resources constraint matches", v)
+	}
+	return nil
+}
+
+// NodesFor returns the node requirements of the given version of Coder, or
+// nil if there are none.
+func (m *Manifest) NodesFor(v *semver.Version) *NodeRequirements {
+	for i := range m.Nodes {
+		if m.Nodes[i].Constraint.Check(v) {
+			return &m.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// Matrix returns the requirements of each version of Coder listed in the
+// manifest, newest first.
+func (m *Manifest) Matrix() []MatrixEntry {
+	entries := make([]MatrixEntry, 0, len(m.Coder))
+	for _, c := range m.Coder {
+		entry := MatrixEntry{
+			CoderVersion:      c.Version.String(),
+			Kubernetes:        c.Kubernetes,
+			Helm:              c.Helm,
+			Resources:         []Resource{},
+			RoleOnlyResources: []Resource{},
+		}
+		if set := m.ResourcesFor(c.Version.Version); set != nil {
+			entry.Resources = append(entry.Resources, set.Resources...)
+			entry.RoleOnlyResources = append(entry.RoleOnlyResources, set.RoleOnlyResources...)
+		}
+		entry.Nodes = m.NodesFor(c.Version.Version)
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// MatrixFormats is the list of formats supported by WriteMatrix.
+var MatrixFormats = []string{"table", "json", "markdown"}
+
+// WriteMatrix writes the given entries to w in the given format.
+func WriteMatrix(w io.Writer, format string, entries []MatrixEntry) error {
+	switch format {
+	case "table":
+		return writeMatrixTable(w, entries)
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(entries)
+	case "markdown":
+		return writeMatrixMarkdown(w, entries)
+	default:
+		return xerrors.Errorf("unknown format %q, must be one of: %s", format, strings.Join(MatrixFormats, ", "))
+	}
+}
+
+// displayConstraint returns the text used for an optional constraint in
+// tables.
+func displayConstraint(c *Constraint) string {
+	if c == nil {
+		return "-"
+	}
+	return c.String()
+}
+
+// displayGroup returns the name used for the given API group in tables.
+func displayGroup(group string) string {
+	if group == "" {
+		return "core"
+	}
+	return group
+}
+
+func writeMatrixTable(w io.Writer, entries []MatrixEntry) error {
+	// The tabwriter buffers its input, so errors writing to w are returned
+	// by Flush.
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "CODER\tKUBERNETES TESTED\tSUPPORTED\tDEPRECATED\tHELM")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", entry.CoderVersion, entry.Kubernetes.Tested,
+			displayConstraint(entry.Kubernetes.Supported), displayConstraint(entry.Kubernetes.Deprecated), entry.Helm)
+	}
+
+	for _, entry := range entries {
+		if entry.Nodes != nil {
+			_, _ = fmt.Fprintf(tw, "\nMinimum node capacity for Coder %s: %s\n", entry.CoderVersion, entry.Nodes)
+		}
+		_, _ = fmt.Fprintf(tw, "\nResources required by Coder %s:\n", entry.CoderVersion)
+		_, _ = fmt.Fprintln(tw, "GROUP\tVERSION\tRESOURCE\tVERBS\tROLE ONLY")
+		for _, r := range entry.Resources {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", displayGroup(r.Group), r.Version, r.Resource, strings.Join(r.Verbs, ","), "no")
+		}
+		for _, r := range entry.RoleOnlyResources {
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", displayGroup(r.Group), r.Version, r.Resource, strings.Join(r.Verbs, ","), "yes")
+		}
+	}
+
+	return tw.Flush()
+}
+
+// escapeCell makes the given text safe to use in a Markdown table cell.
+func escapeCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func writeMatrixMarkdown(w io.Writer, entries []MatrixEntry) error {
+	var b strings.Builder
+	b.WriteString("| Coder | Kubernetes (tested) | Kubernetes (supported) | Kubernetes (deprecated) | Helm |\n" +
+		"| --- | --- | --- | --- | --- |\n")
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", entry.CoderVersion,
+			escapeCell(entry.Kubernetes.Tested.String()),
+			escapeCell(displayConstraint(entry.Kubernetes.Supported)),
+			escapeCell(displayConstraint(entry.Kubernetes.Deprecated)),
+			escapeCell(entry.Helm.String()))
+	}
+
+	for _, entry := range entries {
+		_, _ = fmt.Fprintf(&b, "\n## Coder %s\n\n", entry.CoderVersion)
+		if entry.Nodes != nil {
+			_, _ = fmt.Fprintf(&b, "Minimum node capacity: %s\n\n", entry.Nodes)
+		}
+		b.WriteString("| Group | Version | Resource | Verbs | Role only |\n| --- | --- | --- | --- | --- |\n")
+		for _, r := range entry.Resources {
+			_, _ = fmt.Fprintf(&b, "| %s | %s | %s | %s | no |\n", displayGroup(r.Group), r.Version, r.Resource, strings.Join(r.Verbs, ", "))
+		}
+		for _, r := range entry.RoleOnlyResources {
+			_, _ = fmt.Fprintf(&b, "| %s | %s | %s | %s | yes |\n", displayGroup(r.Group), r.Version, r.Resource, strings.Join(r.Verbs, ", "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,144 @@
+package requirements_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+func TestMatrix(t *testing.T) {
+	t.Parallel()
+
+	m, err := requirements.Parse([]byte(validManifest))
+	assert.Success(t, "parse manifest", err)
+
+	entries := m.Matrix()
+	assert.Equal(t, "one entry per coder version", 2, len(entries))
+	assert.Equal(t, "newest first", "1.21.0", entries[0].CoderVersion)
+	assert.Equal(t, "resources for newest", 2, len(entries[0].Resources))
+	assert.Equal(t, "resources for oldest", 1, len(entries[1].Resources))
+	assert.Equal(t, "helm for oldest", ">= 3.5.0", entries[1].Helm.String())
+	assert.True(t, "nodes for newest", entries[0].Nodes == &m.Nodes[0])
+	assert.True(t, "nodes for 1.19", m.NodesFor(semver.MustParse("1.19.0")) == &m.Nodes[1])
+
+	assert.True(t, "resources for 1.20.5", m.ResourcesFor(semver.MustParse("1.20.5")) == &m.Resources[1])
+	assert.True(t, "no resources for 1.19", m.ResourcesFor(semver.MustParse("1.19.0")) == nil)
+}
+
+func TestCheckVersion(t *testing.T) {
+	t.Parallel()
+
+	m, err := requirements.Parse([]byte(validManifest))
+	assert.Success(t, "parse manifest", err)
+
+	single, err := requirements.Parse([]byte(`
+schemaVersion: 1
+coder:
+  - version: "1.20.0"
+    kubernetes: { tested: ">= 1.19, < 1.22" }
+    helm: ">= 3.5.0"
+resources:
+  - constraint: "~1.20"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: [get] }
+`))
+	assert.Success(t, "parse single version manifest", err)
+
+	tests := []struct {
+		Name     string
+		Manifest *requirements.Manifest
+		Version  string
+		Error    string
+	}{
+		{Name: "listed", Manifest: m, Version: "1.21.0"},
+		{Name: "between listed", Manifest: m, Version: "1.20.3"},
+		{Name: "newer", Manifest: m, Version: "100.0.0"},
+		{Name: "older", Manifest: m, Version: "1.19.0", Error: "no requirements for coder version 1.19.0"},
+		{Name: "no resources", Manifest: single, Version: "1.21.0", Error: "no resources for coder version 1.21.0"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			err := test.Manifest.CheckVersion(semver.MustParse(test.Version))
+			if test.Error == "" {
+				assert.Success(t, "check version", err)
+				return
+			}
+			assert.ErrorContains(t, "check version", err, test.Error)
+		})
+	}
+}
+
+func TestWriteMatrix(t *testing.T) {
+	t.Parallel()
+
+	m, err := requirements.Parse([]byte(validManifest))
+	assert.Success(t, "parse manifest", err)
+	entries := m.Matrix()
+
+	t.Run("table", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		err := requirements.WriteMatrix(&buf, "table", entries)
+		assert.Success(t, "write table", err)
+
+		lines := strings.Split(buf.String(), "\n")
+		assert.Equal(t, "header", "CODER   KUBERNETES TESTED  SUPPORTED  DEPRECATED  HELM", strings.TrimSpace(lines[0]))
+		assert.Equal(t, "first version", "1.21.0  >= 1.19, < 1.23    ~1.23      ~1.19       >= 3.6.0", strings.TrimSpace(lines[1]))
+		assert.Equal(t, "second version", "1.20.0  >= 1.19, < 1.22    -          -           >= 3.5.0", strings.TrimSpace(lines[2]))
+		assert.True(t, "resource rows", strings.Contains(buf.String(), "apps   apps/v1  deployments  get,list  no"))
+		assert.True(t, "node requirements", strings.Contains(buf.String(), "\nMinimum node capacity for Coder 1.20.0: 1 CPU, amd64\n"))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		err := requirements.WriteMatrix(&buf, "json", entries)
+		assert.Success(t, "write json", err)
+
+		var decoded []map[string]interface{}
+		err = json.Unmarshal(buf.Bytes(), &decoded)
+		assert.Success(t, "decode json", err)
+		assert.True(t, "constraints are not escaped", strings.Contains(buf.String(), `"helm": ">= 3.6.0"`))
+		assert.Equal(t, "entries", 2, len(decoded))
+		assert.Equal(t, "coder version", "1.21.0", decoded[0]["coderVersion"])
+		assert.Equal(t, "helm", ">= 3.6.0", decoded[0]["helm"])
+		assert.Equal(t, "kubernetes", map[string]interface{}{"tested": ">= 1.19, < 1.23", "supported": "~1.23", "deprecated": "~1.19"}, decoded[0]["kubernetes"])
+		assert.Equal(t, "role only resources", []interface{}{}, decoded[0]["roleOnlyResources"])
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		t.Parallel()
+
+		var buf bytes.Buffer
+		err := requirements.WriteMatrix(&buf, "markdown", entries)
+		assert.Success(t, "write markdown", err)
+
+		out := buf.String()
+		assert.True(t, "summary table", strings.HasPrefix(out, "| Coder | Kubernetes (tested) | Kubernetes (supported) | Kubernetes (deprecated) | Helm |\n"+
+			"| --- | --- | --- | --- | --- |\n"+
+			"| 1.21.0 | >= 1.19, < 1.23 | ~1.23 | ~1.19 | >= 3.6.0 |\n"))
+		assert.True(t, "version heading", strings.Contains(out, "\n## Coder 1.20.0\n"))
+		assert.True(t, "core group", strings.Contains(out, "| core | v1 | pods | get | no |\n"))
+		assert.True(t, "node requirements", strings.Contains(out, "\n## Coder 1.21.0\n\nMinimum node capacity: 2 CPU, 4Gi memory, amd64 or arm64\n\n"))
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		t.Parallel()
+
+		err := requirements.WriteMatrix(&bytes.Buffer{}, "yaml", entries)
+		assert.ErrorContains(t, "unknown format", err, `unknown format "yaml"`)
+	})
+}
@@ -0,0 +1,387 @@
+// Package requirements describes the versions of Kubernetes and Helm, the
+// Kubernetes resources and RBAC permissions, and the node capacity required
+// by each version of Coder. The defaults are embedded in the binary, and can be replaced
+// with a manifest loaded from a file.
+package requirements
+
+import (
+	"bytes"
+	_ "embed" // embed the default requirements manifest
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"golang.org/x/xerrors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// SchemaVersion is the version of the manifest format understood by this
+// package. It is incremented for incompatible changes.
+const SchemaVersion = 1
+
+//go:embed requirements.yaml
+var defaultManifest []byte
+
+// Manifest lists the requirements of each version of Coder.
+type Manifest struct {
+	SchemaVersion int `json:"schemaVersion"`
+	// Coder lists the Kubernetes and Helm versions supported by each
+	// version of Coder, newest first.
+	Coder []CoderRequirements `json:"coder"`
+	// Resources lists the Kubernetes resources and permissions required
+	// by the versions of Coder matching each constraint, newest first.
+	Resources []ResourceSet `json:"resources"`
+	// Nodes lists the minimum node capacity required by the versions of
+	// Coder matching each constraint, newest first. It is optional, and
+	// node capacity is not checked if it is omitted.
+	Nodes []NodeRequirements `json:"nodes,omitempty"`
+}
+
+// CoderRequirements describes the Kubernetes and Helm versions supported
+// by a version of Coder.
+type CoderRequirements struct {
+	Version    Version                `json:"version"`
+	Kubernetes KubernetesRequirements `json:"kubernetes"`
+	Helm       Constraint             `json:"helm"`
+}
+
+// KubernetesRequirements describes the Kubernetes versions supported by a
+// version of Coder, in tiers. Versions matching Tested are known to work.
+// Versions matching Supported are expected to work, but have not been
+// tested. Versions matching Deprecated still work, but support for them
+// will be removed in a future version of Coder.
+type KubernetesRequirements struct {
+	Tested     Constraint  `json:"tested"`
+	Supported  *Constraint `json:"supported,omitempty"`
+	Deprecated *Constraint `json:"deprecated,omitempty"`
+}
+
+// ResourceSet lists the resources required by the versions of Coder
+// matching a constraint.
+type ResourceSet struct {
+	Constraint Constraint `json:"constraint"`
+	Resources  []Resource `json:"resources"`
+	// RoleOnlyResources are only required because the role in the Helm
+	// chart specifies permissions on resources that don't necessarily
+	// exist in the real world.
+	RoleOnlyResources []Resource `json:"roleOnlyResources,omitempty"`
+}
+
+// Resource is a Kubernetes resource that must exist, and the verbs the
+// user must be permitted to perform on it.
+type Resource struct {
+	Group    string   `json:"group"`
+	Version  string   `json:"version"`
+	Resource string   `json:"resource"`
+	Verbs    []string `json:"verbs"`
+}
+
+// NodeRequirements describes the smallest node able to run the Coder
+// control plane, for the versions of Coder matching a constraint. Zero
+// quantities are not checked.
+type NodeRequirements struct {
+	Constraint       Constraint        `json:"constraint"`
+	CPU              resource.Quantity `json:"cpu"`
+	Memory           resource.Quantity `json:"memory"`
+	EphemeralStorage resource.Quantity `json:"ephemeralStorage"`
+	// Architectures lists the supported node architectures, as given by
+	// the kubernetes.io/arch label.
+	Architectures []string `json:"architectures"`
+}
+
+// String describes the requirements, such as "2 CPU, 4Gi memory, amd64".
+func (n NodeRequirements) String() string {
+	var parts []string
+	if !n.CPU.IsZero() {
+		parts = append(parts, n.CPU.String()+" CPU")
+	}
+	if !n.Memory.IsZero() {
+		parts = append(parts, n.Memory.String()+" memory")
+	}
+	if !n.EphemeralStorage.IsZero() {
+		parts = append(parts, n.EphemeralStorage.String()+" ephemeral storage")
+	}
+	parts = append(parts, strings.Join(n.Architectures, " or "))
+	return strings.Join(parts, ", ")
+}
+
+// Version is a semantic version, encoded as a string.
+type Version struct {
+	*semver.Version
+}
+
+func (v Version) MarshalJSON() ([]byte, error) {
+	if v.Version == nil {
+		return []byte("null"), nil
+	}
+	return marshalString(v.Version.Original())
+}
+
+func (v *Version) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return xerrors.Errorf("version must be a string: %w", err)
+	}
+
+	parsed, err := semver.NewVersion(s)
+	if err != nil {
+		return xerrors.Errorf("parse version %q: %w", s, err)
+	}
+
+	v.Version = parsed
+	return nil
+}
+
+// Constraint is a semantic version constraint, encoded as a string.
+type Constraint struct {
+	*semver.Constraints
+	raw string
+}
+
+func (c *Constraint) parse(s string) error {
+	parsed, err := semver.NewConstraint(s)
+	if err != nil {
+		return xerrors.Errorf("parse constraint %q: %w", s, err)
+	}
+
+	c.Constraints = parsed
+	c.raw = s
+	return nil
+}
+
+// String returns the constraint as written in the manifest.
+func (c Constraint) String() string {
+	return c.raw
+}
+
+func (c Constraint) MarshalJSON() ([]byte, error) {
+	if c.Constraints == nil {
+		return []byte("null"), nil
+	}
+	return marshalString(c.raw)
+}
+
+func (c *Constraint) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return xerrors.Errorf("constraint must be a string: %w", err)
+	}
+	return c.parse(s)
+}
+
+// marshalString encodes s as a JSON string without escaping HTML
+// characters, so that constraints such as ">= 3.6.0" remain readable.
+func marshalString(s string) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(s); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// Default returns the requirements embedded in the binary.
+func Default() *Manifest {
+	m, err := Parse(defaultManifest)
+	if err != nil {
+		panic(xerrors.Errorf("parse default requirements: %w", err))
+	}
+	return m
+}
+
+// Load reads and validates the requirements manifest at path.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, xerrors.Errorf("read requirements: %w", err)
+	}
+
+	m, err := Parse(data)
+	if err != nil {
+		return nil, xerrors.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
+
+// Parse parses and validates a requirements manifest, encoded as YAML or
+// JSON. Unknown fields are rejected.
+func Parse(data []byte) (*Manifest, error) {
+	var m Manifest
+	if err := yaml.UnmarshalStrict(data, &m); err != nil {
+		return nil, xerrors.Errorf("parse requirements: %w", err)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, xerrors.Errorf("invalid requirements: %w", err)
+	}
+	return &m, nil
+}
+
+// Validate returns an error if the manifest is incomplete, its entries are
+// not sorted, or a listed version of Coder matches more or less than one
+// set of resources or, if given, node requirements.
+func (m *Manifest) Validate() error {
+	if m.SchemaVersion != SchemaVersion {
+		return xerrors.Errorf("unsupported schemaVersion %d, expected %d", m.SchemaVersion, SchemaVersion)
+	}
+
+	if len(m.Coder) == 0 {
+		return xerrors.New("coder: at least one version of Coder is required")
+	}
+
+	for i, c := range m.Coder {
+		if err := c.validate(); err != nil {
+			return xerrors.Errorf("coder[%d]: %w", i, err)
+		}
+		if i > 0 && !c.Version.LessThan(m.Coder[i-1].Version.Version) {
+			return xerrors.Errorf("coder[%d]: version %s must be older than %s, entries must be sorted newest first",
+				i, c.Version, m.Coder[i-1].Version)
+		}
+	}
+
+	if len(m.Resources) == 0 {
+		return xerrors.New("resources: at least one set of resources is required")
+	}
+
+	for i, set := range m.Resources {
+		if set.Constraint.Constraints == nil {
+			return xerrors.Errorf("resources[%d]: constraint is required", i)
+		}
+		if len(set.Resources) == 0 {
+			return xerrors.Errorf("resources[%d]: at least one resource is required", i)
+		}
+		if err := validateResources(set.Resources); err != nil {
+			return xerrors.Errorf("resources[%d].resources%w", i, err)
+		}
+		if err := validateResources(set.RoleOnlyResources); err != nil {
+			return xerrors.Errorf("resources[%d].roleOnlyResources%w", i, err)
+		}
+	}
+
+	constraints := make([]Constraint, 0, len(m.Resources))
+	for _, set := range m.Resources {
+		constraints = append(constraints, set.Constraint)
+	}
+	if err := m.validateConstraints("resources", constraints); err != nil {
+		return err
+	}
+
+	if len(m.Nodes) == 0 {
+		return nil
+	}
+
+	constraints = make([]Constraint, 0, len(m.Nodes))
+	for i, nodes := range m.Nodes {
+		if err := nodes.validate(); err != nil {
+			return xerrors.Errorf("nodes[%d]: %w", i, err)
+		}
+		constraints = append(constraints, nodes.Constraint)
+	}
+	if err := m.validateConstraints("nodes", constraints); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// validateConstraints returns an error unless each listed version of Coder
+// matches exactly one of the constraints, which must select the entries
+// in order. Overlapping constraints are detected at each listed version of
+// Coder.
+func (m *Manifest) validateConstraints(field string, constraints []Constraint) error {
+	prev := 0
+	for _, c := range m.Coder {
+		matched := -1
+		for i, constraint := range constraints {
+			if !constraint.Check(c.Version.Version) {
+				continue
+			}
+			if matched >= 0 {
+				return xerrors.Errorf("%s: coder %s matches overlapping constraints %q and %q",
+					field, c.Version, constraints[matched], constraint)
+			}
+			matched = i
+		}
+
+		if matched < 0 {
+			return xerrors.Errorf("%s: coder %s does not match any constraint", field, c.Version)
+		}
+		if matched < prev {
+			return xerrors.Errorf("%s[%d]: constraint %q must be listed after %q, entries must be sorted newest first",
+				field, matched, constraints[matched], constraints[prev])
+		}
+		prev = matched
+	}
+	return nil
+}
+
+func (n NodeRequirements) validate() error {
+	if n.Constraint.Constraints == nil {
+		return xerrors.New("constraint is required")
+	}
+	if n.CPU.Sign() < 0 || n.Memory.Sign() < 0 || n.EphemeralStorage.Sign() < 0 {
+		return xerrors.New("cpu, memory and ephemeralStorage must not be negative")
+	}
+	if len(n.Architectures) == 0 {
+		return xerrors.New("at least one architecture is required")
+	}
+	return nil
+}
+
+func (c CoderRequirements) validate() error {
+	if c.Version.Version == nil {
+		return xerrors.New("version is required")
+	}
+	if c.Kubernetes.Tested.Constraints == nil {
+		return xerrors.New("kubernetes: tested is required")
+	}
+	if c.Helm.Constraints == nil {
+		return xerrors.New("helm is required")
+	}
+	return nil
+}
+
+// validateResources returns an error if a resource is incomplete, or the
+// resources are not strictly sorted by group, version and resource. The
+// error is prefixed with the index of the offending resource.
+func validateResources(resources []Resource) error {
+	for i, r := range resources {
+		if r.Version == "" || r.Resource == "" {
+			return xerrors.Errorf("[%d]: version and resource are required", i)
+		}
+		if len(r.Verbs) == 0 {
+			return xerrors.Errorf("[%d]: at least one verb is required", i)
+		}
+		if i == 0 {
+			continue
+		}
+
+		prev := resources[i-1]
+		if r.key() == prev.key() {
+			return xerrors.Errorf("[%d]: duplicate resource %s", i, r.key())
+		}
+		if r.less(prev) {
+			return xerrors.Errorf("[%d]: %s must be listed before %s, entries must be sorted by group, version and resource",
+				i, r.key(), prev.key())
+		}
+	}
+	return nil
+}
+
+func (r Resource) key() string {
+	return fmt.Sprintf("%s/%s/%s", r.Group, r.Version, r.Resource)
+}
+
+func (r Resource) less(other Resource) bool {
+	if r.Group != other.Group {
+		return r.Group < other.Group
+	}
+	if r.Version != other.Version {
+		return r.Version < other.Version
+	}
+	return r.Resource < other.Resource
+}
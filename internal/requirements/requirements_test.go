@@ -0,0 +1,226 @@
+package requirements_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Masterminds/semver/v3"
+
+	"cdr.dev/slog/sloggers/slogtest/assert"
+
+	"cdr.dev/coder-doctor/internal/requirements"
+)
+
+const validManifest = `
+schemaVersion: 1
+coder:
+  - version: "1.21.0"
+    kubernetes: { tested: ">= 1.19, < 1.23", supported: "~1.23", deprecated: "~1.19" }
+    helm: ">= 3.6.0"
+  - version: "1.20.0"
+    kubernetes: { tested: ">= 1.19, < 1.22" }
+    helm: ">= 3.5.0"
+resources:
+  - constraint: ">= 1.21"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: &all [get, list] }
+      - { group: apps, version: apps/v1, resource: deployments, verbs: *all }
+  - constraint: "~1.20"
+    resources:
+      - { group: "", version: v1, resource: pods, verbs: [get] }
+nodes:
+  - constraint: ">= 1.21.0"
+    cpu: "2"
+    memory: "4Gi"
+    architectures: [amd64, arm64]
+  - constraint: "< 1.21.0"
+    cpu: 1
+    architectures: [amd64]
+`
+
+func TestDefault(t *testing.T) {
+	t.Parallel()
+
+	m := requirements.Default()
+	assert.Success(t, "default requirements are valid", m.Validate())
+	assert.Equal(t, "newest coder version", "1.21.0", m.Coder[0].Version.String())
+	assert.Equal(t, "helm constraint", ">= 3.6.0", m.Coder[0].Helm.String())
+	assert.Equal(t, "verbs from aliases", []string{"create", "delete", "deletecollection", "get", "list", "update", "patch", "watch"},
+		m.Resources[0].Resources[0].Verbs)
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	m, err := requirements.Parse([]byte(validManifest))
+	assert.Success(t, "parse manifest", err)
+	assert.Equal(t, "coder versions", 2, len(m.Coder))
+	assert.Equal(t, "kubernetes tested", ">= 1.19, < 1.23", m.Coder[0].Kubernetes.Tested.String())
+	assert.Equal(t, "kubernetes deprecated", "~1.19", m.Coder[0].Kubernetes.Deprecated.String())
+	assert.True(t, "kubernetes supported is optional", m.Coder[1].Kubernetes.Supported == nil)
+	assert.True(t, "constraint parsed", m.Resources[1].Constraint.Check(semver.MustParse("1.20.3")))
+	assert.Equal(t, "verbs from aliases", []string{"get", "list"}, m.Resources[0].Resources[1].Verbs)
+	assert.Equal(t, "node memory", "4Gi", m.Nodes[0].Memory.String())
+	assert.Equal(t, "numeric node cpu", int64(1), m.Nodes[1].CPU.Value())
+	assert.Equal(t, "node requirements", "2 CPU, 4Gi memory, amd64 or arm64", m.Nodes[0].String())
+
+	// The manifest must round-trip through JSON.
+	data, err := json.Marshal(m)
+	assert.Success(t, "marshal manifest", err)
+	again, err := requirements.Parse(data)
+	assert.Success(t, "parse marshaled manifest", err)
+	assert.Equal(t, "constraint survives round trip", "~1.20", again.Resources[1].Constraint.String())
+	assert.Equal(t, "quantity survives round trip", "4Gi", again.Nodes[0].Memory.String())
+
+	// Node requirements are optional.
+	withoutNodes := validManifest[:strings.Index(validManifest, "nodes:")]
+	m, err = requirements.Parse([]byte(withoutNodes))
+	assert.Success(t, "parse manifest without nodes", err)
+	assert.Equal(t, "no node requirements", 0, len(m.Nodes))
+}
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name    string
+		Old     string
+		New     string
+		Message string
+	}{
+		{
+			Name:    "schema version",
+			Old:     "schemaVersion: 1",
+			New:     "schemaVersion: 2",
+			Message: "unsupported schemaVersion 2",
+		},
+		{
+			Name:    "unknown field",
+			Old:     `helm: ">= 3.6.0"`,
+			New:     `helm: ">= 3.6.0"` + "\n    kubectl: \">= 1.19\"",
+			Message: "unknown field",
+		},
+		{
+			Name:    "unquoted version",
+			Old:     `version: "1.20.0"`,
+			New:     `version: 1.20`,
+			Message: "version must be a string",
+		},
+		{
+			Name:    "bad constraint",
+			Old:     `"~1.20"`,
+			New:     `"nope"`,
+			Message: `parse constraint "nope"`,
+		},
+		{
+			Name:    "missing tested kubernetes versions",
+			Old:     `{ tested: ">= 1.19, < 1.22" }`,
+			New:     `{ supported: ">= 1.19, < 1.22" }`,
+			Message: "coder[1]: kubernetes: tested is required",
+		},
+		{
+			Name:    "unsorted coder versions",
+			Old:     `version: "1.20.0"`,
+			New:     `version: "1.22.0"`,
+			Message: "coder[1]: version 1.22.0 must be older than 1.21.0",
+		},
+		{
+			Name:    "overlapping constraints",
+			Old:     `"~1.20"`,
+			New:     `">= 1.20"`,
+			Message: `coder 1.21.0 matches overlapping constraints ">= 1.21" and ">= 1.20"`,
+		},
+		{
+			Name:    "unmatched coder version",
+			Old:     `"~1.20"`,
+			New:     `"~1.19"`,
+			Message: "coder 1.20.0 does not match any constraint",
+		},
+		{
+			Name:    "unsorted resources",
+			Old:     "{ group: apps, version: apps/v1, resource: deployments, verbs: *all }",
+			New:     "{ group: apps, version: apps/v1, resource: deployments, verbs: *all }\n      - { group: \"\", version: v1, resource: secrets, verbs: *all }",
+			Message: "resources[0].resources[2]: /v1/secrets must be listed before apps/apps/v1/deployments",
+		},
+		{
+			Name:    "duplicate resources",
+			Old:     "{ group: apps, version: apps/v1, resource: deployments, verbs: *all }",
+			New:     "{ group: apps, version: apps/v1, resource: deployments, verbs: *all }\n      - { group: apps, version: apps/v1, resource: deployments, verbs: *all }",
+			Message: "resources[0].resources[2]: duplicate resource apps/apps/v1/deployments",
+		},
+		{
+			Name:    "missing verbs",
+			Old:     "verbs: [get]",
+			New:     "verbs: []",
+			Message: "resources[1].resources[0]: at least one verb is required",
+		},
+		{
+			Name:    "missing node architectures",
+			Old:     "cpu: 1\n    architectures: [amd64]",
+			New:     "cpu: 1",
+			Message: "nodes[1]: at least one architecture is required",
+		},
+		{
+			Name:    "negative node capacity",
+			Old:     "cpu: 1",
+			New:     "cpu: -1",
+			Message: "nodes[1]: cpu, memory and ephemeralStorage must not be negative",
+		},
+		{
+			Name:    "overlapping node constraints",
+			Old:     `"< 1.21.0"`,
+			New:     `"< 1.22.0"`,
+			Message: `nodes: coder 1.21.0 matches overlapping constraints ">= 1.21.0" and "< 1.22.0"`,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			manifest := strings.Replace(validManifest, test.Old, test.New, 1)
+			assert.False(t, "test should modify the manifest", manifest == validManifest)
+
+			_, err := requirements.Parse([]byte(manifest))
+			assert.ErrorContains(t, "invalid manifest", err, test.Message)
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "requirements.yaml")
+	err := os.WriteFile(path, []byte(validManifest), 0o600)
+	assert.Success(t, "write manifest", err)
+
+	m, err := requirements.Load(path)
+	assert.Success(t, "load manifest", err)
+	assert.Equal(t, "coder versions", 2, len(m.Coder))
+
+	_, err = requirements.Load(filepath.Join(dir, "missing.yaml"))
+	assert.ErrorContains(t, "missing manifest", err, "read requirements")
+}
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "schema", "requirements.v1.json"))
+	assert.Success(t, "read schema", err)
+
+	var schema struct {
+		Required   []string               `json:"required"`
+		Properties map[string]interface{} `json:"properties"`
+	}
+	err = json.Unmarshal(data, &schema)
+	assert.Success(t, "parse schema", err)
+	assert.Equal(t, "required properties", []string{"schemaVersion", "coder", "resources"}, schema.Required)
+	for _, name := range schema.Required {
+		assert.True(t, name+" should be described", schema.Properties[name] != nil)
+	}
+}
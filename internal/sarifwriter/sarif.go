@@ -0,0 +1,210 @@
+package sarifwriter
+
+import (
+	"encoding/json"
+	"io"
+
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
+)
+
+var _ = api.ResultWriteCloser(&SARIFWriter{})
+
+const (
+	sarifSchema    = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion   = "2.1.0"
+	toolName       = "coder-doctor"
+	toolInfoURI    = "https://github.com/coder/coder-doctor"
+	unnamedCheckID = "unnamed"
+)
+
+// ruleDescriptions contains descriptions for the checks known to
+// coder-doctor. Checks without an entry here use their name as the
+// description.
+var ruleDescriptions = map[string]string{
+	"kubernetes-version":             "Kubernetes version is compatible with Coder",
+	"kubernetes-namespace":           "Kubernetes namespace exists, or can be created, for installing Coder",
+	"kubernetes-helm-release":        "Existing Coder Helm releases can be upgraded to the selected version",
+	"kubernetes-resources":           "Kubernetes cluster supports the resources required by Coder",
+	"kubernetes-metrics":             "Kubernetes metrics API is available to report workspace resource usage",
+	"kubernetes-rbac":                "Kubernetes RBAC permissions allow installing Coder (SelfSubjectAccessReview)",
+	"kubernetes-rbac-ssrr":           "Kubernetes RBAC permissions allow installing Coder (SelfSubjectRulesReview)",
+	"kubernetes-storage":             "Kubernetes cluster has a default StorageClass for workspace volumes",
+	"kubernetes-ingress":             "Kubernetes cluster has an IngressClass to serve the Coder Ingress",
+	"kubernetes-network-policy":      "Kubernetes network plugin enforces NetworkPolicies to isolate workspaces",
+	"kubernetes-pod-security":        "Kubernetes namespace Pod Security Admission labels allow Coder's pods",
+	"kubernetes-pod-security-policy": "Kubernetes PodSecurityPolicies allow Coder's pods",
+	"kubernetes-resource-quota":      "Kubernetes namespace quota fits the Coder control plane and workspaces",
+	"kubernetes-nodes":               "Kubernetes cluster has a node able to run the Coder control plane",
+	"kubernetes-smoke-pvc":           "Kubernetes cluster provisions a volume for a PersistentVolumeClaim",
+	"kubernetes-smoke-pod":           "Kubernetes cluster starts a pod mounting a provisioned volume",
+	"kubernetes-smoke-loadbalancer":  "Kubernetes cluster provisions a load balancer for a LoadBalancer Service",
+	"kubernetes-smoke-cleanup":       "Resources created by the smoke tests were deleted",
+	"local-helm-version":             "Local Helm version is compatible with Coder",
+}
+
+// SARIFWriter is a writer that collects results and writes them to a
+// stream as a SARIF 2.1.0 log when closed. Each check name becomes a
+// rule, with the check name as its ID.
+type SARIFWriter struct {
+	writer  io.Writer
+	rules   []rule
+	results []result
+	closed  bool
+}
+
+func New(writer io.Writer) *SARIFWriter {
+	return &SARIFWriter{
+		writer: writer,
+	}
+}
+
+type log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []run  `json:"runs"`
+}
+
+type run struct {
+	Tool    tool     `json:"tool"`
+	Results []result `json:"results"`
+}
+
+type tool struct {
+	Driver driver `json:"driver"`
+}
+
+type driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+	Rules          []rule `json:"rules"`
+}
+
+type rule struct {
+	ID               string  `json:"id"`
+	ShortDescription message `json:"shortDescription"`
+}
+
+type result struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Kind       string                 `json:"kind"`
+	Level      string                 `json:"level"`
+	Message    message                `json:"message"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type message struct {
+	Text string `json:"text"`
+}
+
+func (w *SARIFWriter) WriteResult(res *api.CheckResult) error {
+	if w.closed {
+		return xerrors.New("write to closed sarif writer")
+	}
+
+	kind, level, err := kindAndLevel(res.State)
+	if err != nil {
+		return err
+	}
+
+	ruleID := res.Name
+	if ruleID == "" {
+		ruleID = unnamedCheckID
+	}
+
+	w.results = append(w.results, result{
+		RuleID:     ruleID,
+		RuleIndex:  w.ruleIndex(ruleID),
+		Kind:       kind,
+		Level:      level,
+		Message:    message{Text: res.Summary},
+		Properties: res.Details,
+	})
+	return nil
+}
+
+// Close writes the SARIF log for all results written so far. No
+// further results may be written after the writer is closed.
+func (w *SARIFWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	rules := w.rules
+	if rules == nil {
+		rules = []rule{}
+	}
+	results := w.results
+	if results == nil {
+		results = []result{}
+	}
+
+	doc := log{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []run{
+			{
+				Tool: tool{
+					Driver: driver{
+						Name:           toolName,
+						InformationURI: toolInfoURI,
+						Rules:          rules,
+					},
+				},
+				Results: results,
+			},
+		},
+	}
+
+	encoder := json.NewEncoder(w.writer)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(doc); err != nil {
+		return xerrors.Errorf("write sarif log: %w", err)
+	}
+
+	return nil
+}
+
+// ruleIndex returns the index of the rule with the given ID, adding
+// the rule if it has not been seen before.
+func (w *SARIFWriter) ruleIndex(id string) int {
+	for i, r := range w.rules {
+		if r.ID == id {
+			return i
+		}
+	}
+
+	description, ok := ruleDescriptions[id]
+	if !ok {
+		description = id
+	}
+
+	w.rules = append(w.rules, rule{
+		ID:               id,
+		ShortDescription: message{Text: description},
+	})
+	return len(w.rules) - 1
+}
+
+// kindAndLevel maps a CheckState to the SARIF result kind and level.
+func kindAndLevel(state api.CheckState) (string, string, error) {
+	switch state {
+	case api.StatePassed:
+		return "pass", "none", nil
+	case api.StateWarning:
+		return "fail", "warning", nil
+	case api.StateFailed:
+		return "fail", "error", nil
+	case api.StateInfo:
+		// SARIF requires the level "none" for every kind other than "fail".
+		return "informational", "none", nil
+	case api.StateSkipped:
+		return "notApplicable", "none", nil
+	}
+
+	return "", "", xerrors.Errorf("unknown state: %d", state)
+}
@@ -0,0 +1,155 @@
+package sarifwriter_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"cdr.dev/coder-doctor/internal/api"
+	"cdr.dev/coder-doctor/internal/sarifwriter"
+	"cdr.dev/slog/sloggers/slogtest/assert"
+)
+
+func TestSARIFWriter(t *testing.T) {
+	t.Parallel()
+
+	var sb strings.Builder
+	w := sarifwriter.New(&sb)
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-rbac-ssrr",
+		State:   api.StateFailed,
+		Summary: "resource pods: not satisfied",
+		Details: map[string]interface{}{
+			"resource": "pods",
+		},
+	})
+	w.WriteResult(&api.CheckResult{
+		Name:    "local-helm-version",
+		State:   api.StatePassed,
+		Summary: "Coder 1.21.0 supports Helm >= 3.6.0",
+	})
+	w.WriteResult(&api.CheckResult{
+		Name:    "kubernetes-rbac-ssrr",
+		State:   api.StateWarning,
+		Summary: "resource secrets: something odd",
+	})
+	assert.Equal(t, "nothing written before close", "", sb.String())
+
+	err := w.Close()
+	assert.Success(t, "close writer", err)
+
+	expected := `{
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "version": "2.1.0",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "coder-doctor",
+          "informationUri": "https://github.com/coder/coder-doctor",
+          "rules": [
+            {
+              "id": "kubernetes-rbac-ssrr",
+              "shortDescription": {
+                "text": "Kubernetes RBAC permissions allow installing Coder (SelfSubjectRulesReview)"
+              }
+            },
+            {
+              "id": "local-helm-version",
+              "shortDescription": {
+                "text": "Local Helm version is compatible with Coder"
+              }
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "kubernetes-rbac-ssrr",
+          "ruleIndex": 0,
+          "kind": "fail",
+          "level": "error",
+          "message": {
+            "text": "resource pods: not satisfied"
+          },
+          "properties": {
+            "resource": "pods"
+          }
+        },
+        {
+          "ruleId": "local-helm-version",
+          "ruleIndex": 1,
+          "kind": "pass",
+          "level": "none",
+          "message": {
+            "text": "Coder 1.21.0 supports Helm >= 3.6.0"
+          }
+        },
+        {
+          "ruleId": "kubernetes-rbac-ssrr",
+          "ruleIndex": 0,
+          "kind": "fail",
+          "level": "warning",
+          "message": {
+            "text": "resource secrets: something odd"
+          }
+        }
+      ]
+    }
+  ]
+}
+`
+	assert.Equal(t, "sarif log matches", expected, sb.String())
+
+	err = w.WriteResult(&api.CheckResult{State: api.StatePassed})
+	assert.ErrorContains(t, "write after close", err, "closed")
+}
+
+func TestSARIFWriterLevels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		State api.CheckState
+		Kind  string
+		Level string
+	}{
+		{State: api.StatePassed, Kind: "pass", Level: "none"},
+		{State: api.StateWarning, Kind: "fail", Level: "warning"},
+		{State: api.StateFailed, Kind: "fail", Level: "error"},
+		{State: api.StateInfo, Kind: "informational", Level: "none"},
+		{State: api.StateSkipped, Kind: "notApplicable", Level: "none"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.State.String(), func(t *testing.T) {
+			t.Parallel()
+
+			var sb strings.Builder
+			w := sarifwriter.New(&sb)
+			err := w.WriteResult(&api.CheckResult{
+				Name:    "local-helm-version",
+				State:   test.State,
+				Summary: "summary",
+			})
+			assert.Success(t, "write result", err)
+			assert.Success(t, "close writer", w.Close())
+
+			var log struct {
+				Runs []struct {
+					Results []struct {
+						Kind  string `json:"kind"`
+						Level string `json:"level"`
+					} `json:"results"`
+				} `json:"runs"`
+			}
+			assert.Success(t, "decode sarif log", json.Unmarshal([]byte(sb.String()), &log))
+			result := log.Runs[0].Results[0]
+			assert.Equal(t, "kind", test.Kind, result.Kind)
+			assert.Equal(t, "level", test.Level, result.Level)
+			if result.Kind != "fail" {
+				assert.Equal(t, "level for non-fail kind", "none", result.Level)
+			}
+		})
+	}
+}
@@ -11,6 +11,21 @@ type SummaryResult struct {
 	Total   int `json:"total"`
 }
 
+// ExitCode returns the process exit code for this summary. Failed
+// results always produce api.ExitCodeFailed. Warnings produce
+// api.ExitCodeWarning only if failOn is api.StateWarning.
+func (s SummaryResult) ExitCode(failOn api.CheckState) int {
+	if s.Failed > 0 {
+		return api.ExitCodeFailed
+	}
+
+	if failOn == api.StateWarning && s.Warning > 0 {
+		return api.ExitCodeWarning
+	}
+
+	return api.ExitCodeOK
+}
+
 var _ = api.ResultWriter(&SummaryWriter{})
 
 type SummaryWriter struct {
@@ -50,3 +65,8 @@ func (w *SummaryWriter) Reset() {
 func (w *SummaryWriter) Summary() SummaryResult {
 	return w.summary
 }
+
+// Close closes the wrapped writer, if it supports closing.
+func (w *SummaryWriter) Close() error {
+	return api.CloseWriter(w.writer)
+}
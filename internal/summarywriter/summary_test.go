@@ -32,3 +32,54 @@ func TestSummaryWriter(t *testing.T) {
 	}
 	assert.Equal(t, "summary is correct", expected, w.Summary())
 }
+
+func TestSummaryExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		Name     string
+		Summary  summarywriter.SummaryResult
+		FailOn   api.CheckState
+		Expected int
+	}{
+		{
+			Name:     "all-passed",
+			Summary:  summarywriter.SummaryResult{Passed: 3, Info: 1, Skipped: 1, Total: 5},
+			FailOn:   api.StateWarning,
+			Expected: api.ExitCodeOK,
+		},
+		{
+			Name:     "failed",
+			Summary:  summarywriter.SummaryResult{Passed: 3, Warning: 1, Failed: 1, Total: 5},
+			FailOn:   api.StateFailed,
+			Expected: api.ExitCodeFailed,
+		},
+		{
+			Name:     "failed-and-warning-fail-on-warning",
+			Summary:  summarywriter.SummaryResult{Warning: 1, Failed: 1, Total: 2},
+			FailOn:   api.StateWarning,
+			Expected: api.ExitCodeFailed,
+		},
+		{
+			Name:     "warning-fail-on-failed",
+			Summary:  summarywriter.SummaryResult{Passed: 1, Warning: 1, Total: 2},
+			FailOn:   api.StateFailed,
+			Expected: api.ExitCodeOK,
+		},
+		{
+			Name:     "warning-fail-on-warning",
+			Summary:  summarywriter.SummaryResult{Passed: 1, Warning: 1, Total: 2},
+			FailOn:   api.StateWarning,
+			Expected: api.ExitCodeWarning,
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, "exit code matches", test.Expected, test.Summary.ExitCode(test.FailOn))
+		})
+	}
+}
@@ -4,7 +4,12 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
+	"golang.org/x/xerrors"
+
+	"cdr.dev/coder-doctor/internal/api"
 	"cdr.dev/coder-doctor/internal/cmd"
 )
 
@@ -15,11 +20,21 @@ func main() {
 		}
 		os.Exit(1)
 	}()
+	// Cancel the checks on interrupt, so that resources created by them
+	// can be cleaned up before exiting.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	command := cmd.NewDefaultDoctorCommand()
-	err := command.ExecuteContext(context.Background())
+	err := command.ExecuteContext(ctx)
+	stop()
+
+	var exitErr *api.ExitError
+	if xerrors.As(err, &exitErr) {
+		os.Exit(exitErr.Code)
+	}
+
 	if err != nil {
-		os.Exit(1)
+		os.Exit(api.ExitCodeError)
 	}
 
-	os.Exit(0)
+	os.Exit(api.ExitCodeOK)
 }